@@ -0,0 +1,109 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyServiceInterface interface {
+	CreateAPIKey(ctx context.Context, adminID int, body *CreateAPIKeyRequest) (*CreatedAPIKey, *ErrorResponse)
+	ListAPIKeys(ctx context.Context, filter *APIKeysFilter) (*APIKeysResponse, *ErrorResponse)
+	RevokeAPIKey(ctx context.Context, keyID int) (*APIKey, *ErrorResponse)
+}
+
+type APIKeyHandler struct {
+	service APIKeyServiceInterface
+}
+
+func NewAPIKeyHandler(service APIKeyServiceInterface) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: service,
+	}
+}
+
+// @Summary      Create API key
+// @Description  Issue a key that acts as the given user within its scopes. The key is returned only in this response
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      CreateAPIKeyRequest  true  "Key"
+// @Success      200   {object}  CreatedAPIKey
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/api-keys [post]
+func (kh *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var body CreateAPIKeyRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	key, err := kh.service.CreateAPIKey(c, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// @Summary      List API keys
+// @Description  List API keys, including revoked and expired ones, newest first
+// @Tags         admin
+// @Produce      json
+// @Param        user_id  query     int  false  "Only keys of this user"
+// @Success      200      {object}  APIKeysResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/api-keys [get]
+func (kh *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	var filter APIKeysFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	keys, err := kh.service.ListAPIKeys(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary      Revoke API key
+// @Description  Revoke a key. Requests with it are rejected immediately
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {object}  APIKey
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/api-keys/{id} [delete]
+func (kh *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid API key ID")
+		return
+	}
+
+	key, err := kh.service.RevokeAPIKey(c, keyID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
@@ -0,0 +1,109 @@
+package apikeys
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/lib/pq"
+)
+
+// Scopes are the permissions a key may be granted. A key never gets more
+// than its user's role allows, whatever its scopes.
+var Scopes = []string{rbac.RidesCreate, rbac.RidesRead, rbac.RidesCancel}
+
+const (
+	// keyPrefix starts every key, so leaked keys are easy to recognize in
+	// code and logs. It is followed by the key's public prefix and secret.
+	keyPrefix       = "gr_"
+	publicPrefixLen = 12
+	maxNameLength   = 100
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	errAPIKeyNotFound = errors.New("api key not found")
+	errUserNotFound   = errors.New("user not found")
+)
+
+// APIKey lets a partner or service act as the user it was issued for without
+// the user's password. Only a hash of the secret is stored.
+type APIKey struct {
+	ID         int            `json:"id" db:"id"`
+	UserID     int            `json:"user_id" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Prefix     string         `json:"prefix" db:"prefix"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes" swaggertype:"array,string"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedBy  *int           `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	UserID    int        `json:"user_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes" example:"rides:create,rides:read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey is returned once, when the key is created: Key can't be
+// recovered afterwards.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeysFilter struct {
+	UserID int `form:"user_id"`
+}
+
+type APIKeysResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+// Principal is who a request authenticated with an API key acts as.
+type Principal struct {
+	KeyID  int
+	UserID int
+	Role   string
+	Scopes []string
+}
+
+type activeKey struct {
+	ID      int            `db:"id"`
+	UserID  int            `db:"user_id"`
+	KeyHash string         `db:"key_hash"`
+	Scopes  pq.StringArray `db:"scopes"`
+	Role    string         `db:"role"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
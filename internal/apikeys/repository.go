@@ -0,0 +1,121 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	apiKeyColumns           = "id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_by, created_at"
+	foreignKeyViolationCode = "23503"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) CreateAPIKey(ctx context.Context, key *APIKey, keyHash string) (*APIKey, error) {
+	var created APIKey
+
+	err := pr.db.GetContext(ctx, &created, "INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+apiKeyColumns,
+		key.UserID, key.Name, key.Prefix, keyHash, key.Scopes, key.ExpiresAt, key.CreatedBy,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolationCode {
+			return nil, errUserNotFound
+		}
+		pr.logger.Error("failed to create api key",
+			slog.Int("user_id", key.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &created, nil
+}
+
+// ListAPIKeys returns the keys of the user, or of every user when userID
+// is 0, newest first.
+func (pr *postgresRepo) ListAPIKeys(ctx context.Context, userID int) ([]APIKey, error) {
+	keys := []APIKey{}
+
+	err := pr.db.SelectContext(ctx, &keys, "SELECT "+apiKeyColumns+" FROM api_keys WHERE $1 = 0 OR user_id = $1 ORDER BY id DESC", userID)
+	if err != nil {
+		pr.logger.Error("failed to list api keys",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (pr *postgresRepo) RevokeAPIKey(ctx context.Context, keyID int) (*APIKey, error) {
+	var key APIKey
+
+	err := pr.db.GetContext(ctx, &key, "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL RETURNING "+apiKeyColumns, keyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAPIKeyNotFound
+		}
+		pr.logger.Error("failed to revoke api key",
+			slog.Int("api_key_id", keyID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// GetActiveAPIKey finds a usable key by its public prefix: not revoked, not
+// expired and belonging to a user who isn't suspended.
+func (pr *postgresRepo) GetActiveAPIKey(ctx context.Context, prefix string) (*activeKey, error) {
+	var key activeKey
+
+	err := pr.db.GetContext(ctx, &key, `SELECT k.id, k.user_id, k.key_hash, k.scopes, u.role
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.prefix = $1
+			AND k.revoked_at IS NULL
+			AND (k.expires_at IS NULL OR k.expires_at > now())
+			AND u.suspended_at IS NULL`, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAPIKeyNotFound
+		}
+		pr.logger.Error("failed to get api key",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// TouchAPIKey records that the key was used. It writes at most once a
+// minute per key, so busy integrations don't update the row on every request.
+func (pr *postgresRepo) TouchAPIKey(ctx context.Context, keyID int) error {
+	_, err := pr.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = now() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')", keyID)
+	if err != nil {
+		pr.logger.Error("failed to update api key usage",
+			slog.Int("api_key_id", keyID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+	return nil
+}
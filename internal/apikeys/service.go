@@ -0,0 +1,186 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type RepositoryInterface interface {
+	CreateAPIKey(ctx context.Context, key *APIKey, keyHash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID int) (*APIKey, error)
+	GetActiveAPIKey(ctx context.Context, prefix string) (*activeKey, error)
+	TouchAPIKey(ctx context.Context, keyID int) error
+}
+
+type APIKeyService struct {
+	repo   RepositoryInterface
+	logger *slog.Logger
+}
+
+func NewAPIKeyService(repository RepositoryInterface, logger *slog.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+func (ks *APIKeyService) CreateAPIKey(ctx context.Context, adminID int, body *CreateAPIKeyRequest) (*CreatedAPIKey, *ErrorResponse) {
+	key := &APIKey{
+		UserID:    body.UserID,
+		Name:      strings.TrimSpace(body.Name),
+		ExpiresAt: body.ExpiresAt,
+		CreatedBy: &adminID,
+	}
+
+	if key.UserID <= 0 {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("user_id is required"))
+	}
+	if n := utf8.RuneCountInString(key.Name); n == 0 || n > maxNameLength {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("name must be between 1 and %d characters", maxNameLength))
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("expires_at must be in the future"))
+	}
+
+	scopes, err := normalizeScopes(body.Scopes)
+	if err != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, err)
+	}
+	key.Scopes = scopes
+
+	prefix, secret, err := newKey()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	key.Prefix = prefix
+
+	created, err := ks.repo.CreateAPIKey(ctx, key, hashSecret(secret))
+	if err != nil {
+		if errors.Is(err, errUserNotFound) {
+			return nil, NewErrorResponseWithStatus(http.StatusNotFound, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	ks.logger.Info("api key created",
+		slog.Int("api_key_id", created.ID),
+		slog.Int("user_id", created.UserID),
+		slog.Int("admin_id", adminID),
+	)
+
+	return &CreatedAPIKey{APIKey: *created, Key: formatKey(prefix, secret)}, nil
+}
+
+func (ks *APIKeyService) ListAPIKeys(ctx context.Context, filter *APIKeysFilter) (*APIKeysResponse, *ErrorResponse) {
+	keys, err := ks.repo.ListAPIKeys(ctx, filter.UserID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &APIKeysResponse{APIKeys: keys}, nil
+}
+
+func (ks *APIKeyService) RevokeAPIKey(ctx context.Context, keyID int) (*APIKey, *ErrorResponse) {
+	key, err := ks.repo.RevokeAPIKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, errAPIKeyNotFound) {
+			return nil, NewErrorResponseWithStatus(http.StatusNotFound, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	ks.logger.Info("api key revoked",
+		slog.Int("api_key_id", keyID),
+	)
+
+	return key, nil
+}
+
+// Authenticate returns who the key acts as, or ErrInvalidAPIKey if the key
+// is unknown, revoked, expired or its user is suspended.
+func (ks *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*Principal, error) {
+	prefix, secret, ok := parseKey(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := ks.repo.GetActiveAPIKey(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, errAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	_ = ks.repo.TouchAPIKey(ctx, key.ID)
+
+	return &Principal{KeyID: key.ID, UserID: key.UserID, Role: key.Role, Scopes: key.Scopes}, nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q, known scopes are %s", scope, strings.Join(Scopes, ", "))
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// newKey returns the public prefix the key is looked up by and the secret
+// only its hash is stored of.
+func newKey() (string, string, error) {
+	b := make([]byte, publicPrefixLen/2+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(b[:publicPrefixLen/2]), hex.EncodeToString(b[publicPrefixLen/2:]), nil
+}
+
+// formatKey builds the key handed to the client: gr_<prefix>_<secret>.
+func formatKey(prefix, secret string) string {
+	return keyPrefix + prefix + "_" + secret
+}
+
+func parseKey(rawKey string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(rawKey, keyPrefix)
+	if !ok {
+		return "", "", false
+	}
+
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != publicPrefixLen || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
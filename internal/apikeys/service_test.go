@@ -0,0 +1,33 @@
+package apikeys
+
+import "testing"
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		rawKey     string
+		wantPrefix string
+		wantSecret string
+		wantOK     bool
+	}{
+		{"valid", "gr_abcdef123456_s3cr3t", "abcdef123456", "s3cr3t", true},
+		{"secret with underscores", "gr_abcdef123456_s3_cr_3t", "abcdef123456", "s3_cr_3t", true},
+		{"round trip", formatKey("ABCDEF123456", "secret"), "ABCDEF123456", "secret", true},
+		{"missing key prefix", "abcdef123456_s3cr3t", "", "", false},
+		{"other key prefix", "gx_abcdef123456_s3cr3t", "", "", false},
+		{"short public prefix", "gr_abcdef12345_s3cr3t", "", "", false},
+		{"long public prefix", "gr_abcdef1234567_s3cr3t", "", "", false},
+		{"no secret", "gr_abcdef123456_", "", "", false},
+		{"no separator", "gr_abcdef123456", "", "", false},
+		{"empty", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, secret, ok := parseKey(tt.rawKey)
+			if prefix != tt.wantPrefix || secret != tt.wantSecret || ok != tt.wantOK {
+				t.Errorf("parseKey(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.rawKey, prefix, secret, ok, tt.wantPrefix, tt.wantSecret, tt.wantOK)
+			}
+		})
+	}
+}
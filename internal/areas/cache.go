@@ -0,0 +1,79 @@
+package areas
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// areaCacheTTL bounds how long an instance keeps areas changed through
+// another instance. Changes made through this instance apply at once.
+const areaCacheTTL = time.Minute
+
+type locatedArea struct {
+	area Area
+	geom *geometry
+}
+
+// areaCache holds the active areas with their geometry already parsed, so
+// Locate doesn't read and decode every area on each call.
+type areaCache struct {
+	mu        sync.Mutex
+	areas     []locatedArea
+	expiresAt time.Time
+}
+
+func (ac *areaCache) get() ([]locatedArea, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if ac.areas == nil || time.Now().After(ac.expiresAt) {
+		return nil, false
+	}
+	return ac.areas, true
+}
+
+func (ac *areaCache) set(areas []locatedArea) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.areas = areas
+	ac.expiresAt = time.Now().Add(areaCacheTTL)
+}
+
+func (ac *areaCache) invalidate() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.areas = nil
+}
+
+// activeAreas returns the cached active areas, loading them on a miss.
+// Areas whose stored geometry doesn't parse are logged and skipped.
+func (as *AreaService) activeAreas(ctx context.Context) ([]locatedArea, error) {
+	if areas, ok := as.cache.get(); ok {
+		return areas, nil
+	}
+
+	areas, err := as.repo.ListAreas(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	located := make([]locatedArea, 0, len(areas))
+	for _, area := range areas {
+		geom, err := parseGeometry(area.Geometry)
+		if err != nil {
+			as.logger.Error("invalid service area geometry",
+				slog.Int("area_id", area.ID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		located = append(located, locatedArea{area: area, geom: geom})
+	}
+
+	as.cache.set(located)
+	return located, nil
+}
@@ -0,0 +1,143 @@
+package areas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const (
+	polygonType      = "Polygon"
+	multiPolygonType = "MultiPolygon"
+	minRingPositions = 4
+)
+
+// polygon is a list of linear rings: the first one is the outer boundary,
+// the rest are holes.
+type polygon [][][]float64
+
+type geometry struct {
+	polygons []polygon
+	// bbox is the bounding box of all outer rings as min lon, min lat,
+	// max lon, max lat. Points outside it skip the ring tests.
+	bbox [4]float64
+}
+
+// parseGeometry decodes and validates a GeoJSON Polygon or MultiPolygon.
+func parseGeometry(raw json.RawMessage) (*geometry, error) {
+	var header struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, fmt.Errorf("geometry must be a GeoJSON object")
+	}
+
+	var polygons []polygon
+	switch header.Type {
+	case polygonType:
+		var p polygon
+		if err := json.Unmarshal(header.Coordinates, &p); err != nil {
+			return nil, fmt.Errorf("invalid Polygon coordinates")
+		}
+		polygons = []polygon{p}
+	case multiPolygonType:
+		if err := json.Unmarshal(header.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("invalid MultiPolygon coordinates")
+		}
+	default:
+		return nil, fmt.Errorf(`geometry type must be "Polygon" or "MultiPolygon"`)
+	}
+
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("geometry must contain at least one polygon")
+	}
+
+	for _, p := range polygons {
+		if err := validatePolygon(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return &geometry{polygons: polygons, bbox: boundingBox(polygons)}, nil
+}
+
+func boundingBox(polygons []polygon) [4]float64 {
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range polygons {
+		for _, pos := range p[0] {
+			bbox[0] = math.Min(bbox[0], pos[0])
+			bbox[1] = math.Min(bbox[1], pos[1])
+			bbox[2] = math.Max(bbox[2], pos[0])
+			bbox[3] = math.Max(bbox[3], pos[1])
+		}
+	}
+	return bbox
+}
+
+func validatePolygon(p polygon) error {
+	if len(p) == 0 {
+		return fmt.Errorf("polygon must have an outer ring")
+	}
+
+	for _, ring := range p {
+		if len(ring) < minRingPositions {
+			return fmt.Errorf("polygon ring must have at least %d positions", minRingPositions)
+		}
+
+		for _, pos := range ring {
+			if len(pos) < 2 || math.IsNaN(pos[0]) || math.IsNaN(pos[1]) {
+				return fmt.Errorf("polygon position must be [lon, lat]")
+			}
+			if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+				return fmt.Errorf("polygon position is out of range")
+			}
+		}
+
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return fmt.Errorf("polygon ring must be closed")
+		}
+	}
+
+	return nil
+}
+
+func (g *geometry) contains(lon, lat float64) bool {
+	if lon < g.bbox[0] || lat < g.bbox[1] || lon > g.bbox[2] || lat > g.bbox[3] {
+		return false
+	}
+
+	for _, p := range g.polygons {
+		if !ringContains(p[0], lon, lat) {
+			continue
+		}
+
+		inHole := false
+		for _, hole := range p[1:] {
+			if ringContains(hole, lon, lat) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains uses ray casting; treating coordinates as planar is accurate
+// enough at city scale.
+func ringContains(ring [][]float64, lon, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,80 @@
+package areas
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// squareWithHole is a 10x10 polygon at the origin with a 2x2 hole in the middle.
+const squareWithHole = `{"type": "Polygon", "coordinates": [
+	[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+	[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+]}`
+
+// twoIslands is a MultiPolygon of two separate unit squares.
+const twoIslands = `{"type": "MultiPolygon", "coordinates": [
+	[[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]],
+	[[[5, 5], [6, 5], [6, 6], [5, 6], [5, 5]]]
+]}`
+
+// concave is an L shape: the top right quarter is cut out.
+const concave = `{"type": "Polygon", "coordinates": [
+	[[0, 0], [10, 0], [10, 5], [5, 5], [5, 10], [0, 10], [0, 0]]
+]}`
+
+func TestGeometryContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry string
+		lon, lat float64
+		want     bool
+	}{
+		{"inside", squareWithHole, 2, 2, true},
+		{"outside", squareWithHole, 11, 5, false},
+		{"outside the bounding box", squareWithHole, -1, -1, false},
+		{"in the hole", squareWithHole, 5, 5, false},
+		{"between hole and edge", squareWithHole, 7, 5, true},
+		{"first island", twoIslands, 0.5, 0.5, true},
+		{"second island", twoIslands, 5.5, 5.5, true},
+		{"between the islands", twoIslands, 3, 3, false},
+		{"concave inside", concave, 2, 8, true},
+		{"concave cut out", concave, 8, 8, false},
+		{"concave inside the bounding box only", concave, 7, 7, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geom, err := parseGeometry(json.RawMessage(tt.geometry))
+			if err != nil {
+				t.Fatalf("parseGeometry: %v", err)
+			}
+			if got := geom.contains(tt.lon, tt.lat); got != tt.want {
+				t.Errorf("contains(%v, %v) = %v, want %v", tt.lon, tt.lat, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGeometryErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry string
+	}{
+		{"not an object", `[1, 2]`},
+		{"point", `{"type": "Point", "coordinates": [1, 2]}`},
+		{"empty multipolygon", `{"type": "MultiPolygon", "coordinates": []}`},
+		{"no rings", `{"type": "Polygon", "coordinates": []}`},
+		{"too few positions", `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`},
+		{"open ring", `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`},
+		{"out of range", `{"type": "Polygon", "coordinates": [[[0, 0], [181, 0], [1, 1], [0, 0]]]}`},
+		{"short position", `{"type": "Polygon", "coordinates": [[[0, 0], [1], [1, 1], [0, 0]]]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseGeometry(json.RawMessage(tt.geometry)); err == nil {
+				t.Errorf("parseGeometry(%s) succeeded, want an error", tt.geometry)
+			}
+		})
+	}
+}
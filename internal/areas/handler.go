@@ -0,0 +1,163 @@
+package areas
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AreaServiceInterface interface {
+	CreateArea(ctx context.Context, body *AreaRequest) (*Area, *ErrorResponse)
+	GetArea(ctx context.Context, areaID int) (*Area, *ErrorResponse)
+	ListAreas(ctx context.Context) (*AreasResponse, *ErrorResponse)
+	UpdateArea(ctx context.Context, areaID int, body *AreaRequest) (*Area, *ErrorResponse)
+	DeleteArea(ctx context.Context, areaID int) (*StatusResponse, *ErrorResponse)
+}
+
+type AreaHandler struct {
+	service AreaServiceInterface
+}
+
+func NewAreaHandler(service AreaServiceInterface) *AreaHandler {
+	return &AreaHandler{
+		service: service,
+	}
+}
+
+// @Summary      Create service area
+// @Description  Create a service area from a GeoJSON Polygon or MultiPolygon
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      AreaRequestSwagger  true  "Service area"
+// @Success      200   {object}  AreaSwagger
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/areas [post]
+func (ah *AreaHandler) CreateArea(c *gin.Context) {
+	var body AreaRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	area, err := ah.service.CreateArea(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// @Summary      List service areas
+// @Description  Get all service areas, including inactive ones
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  AreasResponseSwagger
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/areas [get]
+func (ah *AreaHandler) ListAreas(c *gin.Context) {
+	areas, err := ah.service.ListAreas(c)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, areas)
+}
+
+// @Summary      Get service area
+// @Description  Get service area by ID
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Area ID"
+// @Success      200  {object}  AreaSwagger
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/areas/{id} [get]
+func (ah *AreaHandler) GetArea(c *gin.Context) {
+	areaID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid area ID")
+		return
+	}
+
+	area, err := ah.service.GetArea(c, areaID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// @Summary      Update service area
+// @Description  Replace service area name, city, geometry and active flag
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                 true  "Area ID"
+// @Param        body  body      AreaRequestSwagger  true  "Service area"
+// @Success      200   {object}  AreaSwagger
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/areas/{id} [put]
+func (ah *AreaHandler) UpdateArea(c *gin.Context) {
+	areaID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid area ID")
+		return
+	}
+
+	var body AreaRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	area, err := ah.service.UpdateArea(c, areaID, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// @Summary      Delete service area
+// @Description  Delete a service area that no ride refers to
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Area ID"
+// @Success      200  {object}  StatusResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/areas/{id} [delete]
+func (ah *AreaHandler) DeleteArea(c *gin.Context) {
+	areaID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid area ID")
+		return
+	}
+
+	status, err := ah.service.DeleteArea(c, areaID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
@@ -0,0 +1,85 @@
+package areas
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrOutsideServiceArea = errors.New("location is outside of the service area")
+	errAreaNotFound       = errors.New("service area not found")
+	errAreaInUse          = errors.New("service area is referenced by rides and can't be deleted, deactivate it instead")
+)
+
+type Area struct {
+	ID        int             `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	City      string          `json:"city" db:"city"`
+	Geometry  json.RawMessage `json:"geometry" db:"geometry"`
+	IsActive  bool            `json:"is_active" db:"is_active"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type AreaRequest struct {
+	Name     string          `json:"name"`
+	City     string          `json:"city"`
+	Geometry json.RawMessage `json:"geometry"`
+	IsActive *bool           `json:"is_active,omitempty"`
+}
+
+type AreasResponse struct {
+	Areas []Area `json:"areas"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+// Only for Swagger
+type AreaSwagger struct {
+	ID        int                    `json:"id"`
+	Name      string                 `json:"name"`
+	City      string                 `json:"city"`
+	Geometry  map[string]interface{} `json:"geometry"`
+	IsActive  bool                   `json:"is_active"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+type AreaRequestSwagger struct {
+	Name     string                 `json:"name"`
+	City     string                 `json:"city"`
+	Geometry map[string]interface{} `json:"geometry"`
+	IsActive *bool                  `json:"is_active,omitempty"`
+}
+
+type AreasResponseSwagger struct {
+	Areas []AreaSwagger `json:"areas"`
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
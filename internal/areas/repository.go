@@ -0,0 +1,122 @@
+package areas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	areaColumns             = "id, name, city, geometry, is_active, created_at, updated_at"
+	foreignKeyViolationCode = "23503"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) CreateArea(ctx context.Context, area *Area) (*Area, error) {
+	var created Area
+
+	err := pr.db.GetContext(ctx, &created, "INSERT INTO service_areas (name, city, geometry, is_active) VALUES ($1, $2, $3, $4) RETURNING "+areaColumns, area.Name, area.City, area.Geometry, area.IsActive)
+	if err != nil {
+		pr.logger.Error("failed to create service area",
+			slog.String("name", area.Name),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create service area: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (pr *postgresRepo) GetAreaByID(ctx context.Context, areaID int) (*Area, error) {
+	var area Area
+
+	err := pr.db.GetContext(ctx, &area, "SELECT "+areaColumns+" FROM service_areas WHERE id = $1", areaID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAreaNotFound
+		}
+		pr.logger.Error("failed to get service area",
+			slog.Int("area_id", areaID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get service area: %w", err)
+	}
+
+	return &area, nil
+}
+
+func (pr *postgresRepo) ListAreas(ctx context.Context, activeOnly bool) ([]Area, error) {
+	areas := []Area{}
+
+	query := "SELECT " + areaColumns + " FROM service_areas"
+	if activeOnly {
+		query += " WHERE is_active"
+	}
+	query += " ORDER BY id"
+
+	err := pr.db.SelectContext(ctx, &areas, query)
+	if err != nil {
+		pr.logger.Error("failed to list service areas",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list service areas: %w", err)
+	}
+
+	return areas, nil
+}
+
+func (pr *postgresRepo) UpdateArea(ctx context.Context, area *Area) (*Area, error) {
+	var updated Area
+
+	err := pr.db.GetContext(ctx, &updated, "UPDATE service_areas SET name = $1, city = $2, geometry = $3, is_active = $4, updated_at = now() WHERE id = $5 RETURNING "+areaColumns, area.Name, area.City, area.Geometry, area.IsActive, area.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errAreaNotFound
+		}
+		pr.logger.Error("failed to update service area",
+			slog.Int("area_id", area.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update service area: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func (pr *postgresRepo) DeleteArea(ctx context.Context, areaID int) error {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM service_areas WHERE id = $1", areaID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolationCode {
+			return errAreaInUse
+		}
+		pr.logger.Error("failed to delete service area",
+			slog.Int("area_id", areaID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to delete service area: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete service area: %w", err)
+	}
+	if deleted == 0 {
+		return errAreaNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package areas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type RepositoryInterface interface {
+	CreateArea(ctx context.Context, area *Area) (*Area, error)
+	GetAreaByID(ctx context.Context, areaID int) (*Area, error)
+	ListAreas(ctx context.Context, activeOnly bool) ([]Area, error)
+	UpdateArea(ctx context.Context, area *Area) (*Area, error)
+	DeleteArea(ctx context.Context, areaID int) error
+}
+
+type AreaService struct {
+	repo   RepositoryInterface
+	cache  areaCache
+	logger *slog.Logger
+}
+
+func NewAreaService(repository RepositoryInterface, logger *slog.Logger) *AreaService {
+	return &AreaService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+func (as *AreaService) CreateArea(ctx context.Context, body *AreaRequest) (*Area, *ErrorResponse) {
+	area, errResp := areaFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	created, err := as.repo.CreateArea(ctx, area)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	as.cache.invalidate()
+
+	as.logger.Info("service area created",
+		slog.Int("area_id", created.ID),
+	)
+
+	return created, nil
+}
+
+func (as *AreaService) GetArea(ctx context.Context, areaID int) (*Area, *ErrorResponse) {
+	area, err := as.repo.GetAreaByID(ctx, areaID)
+	if err != nil {
+		return nil, areaErrorResponse(err)
+	}
+	return area, nil
+}
+
+func (as *AreaService) ListAreas(ctx context.Context) (*AreasResponse, *ErrorResponse) {
+	areas, err := as.repo.ListAreas(ctx, false)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &AreasResponse{Areas: areas}, nil
+}
+
+func (as *AreaService) UpdateArea(ctx context.Context, areaID int, body *AreaRequest) (*Area, *ErrorResponse) {
+	area, errResp := areaFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+	area.ID = areaID
+
+	updated, err := as.repo.UpdateArea(ctx, area)
+	if err != nil {
+		return nil, areaErrorResponse(err)
+	}
+	as.cache.invalidate()
+
+	as.logger.Info("service area updated",
+		slog.Int("area_id", areaID),
+	)
+
+	return updated, nil
+}
+
+func (as *AreaService) DeleteArea(ctx context.Context, areaID int) (*StatusResponse, *ErrorResponse) {
+	err := as.repo.DeleteArea(ctx, areaID)
+	if err != nil {
+		return nil, areaErrorResponse(err)
+	}
+	as.cache.invalidate()
+
+	as.logger.Info("service area deleted",
+		slog.Int("area_id", areaID),
+	)
+
+	return &StatusResponse{Status: "deleted"}, nil
+}
+
+// Locate returns the active service area containing the point, or
+// ErrOutsideServiceArea if there is none.
+func (as *AreaService) Locate(ctx context.Context, lon, lat float64) (*Area, error) {
+	areas, err := as.activeAreas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range areas {
+		if areas[i].geom.contains(lon, lat) {
+			area := areas[i].area
+			return &area, nil
+		}
+	}
+
+	return nil, ErrOutsideServiceArea
+}
+
+func areaFromRequest(body *AreaRequest) (*Area, *ErrorResponse) {
+	name := strings.TrimSpace(body.Name)
+	city := strings.TrimSpace(body.City)
+	if name == "" || city == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("name and city are required"))
+	}
+
+	if _, err := parseGeometry(body.Geometry); err != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, err)
+	}
+
+	isActive := true
+	if body.IsActive != nil {
+		isActive = *body.IsActive
+	}
+
+	return &Area{Name: name, City: city, Geometry: body.Geometry, IsActive: isActive}, nil
+}
+
+func areaErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, errAreaNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errAreaInUse):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditServiceInterface interface {
+	ListLogs(ctx context.Context, filter *Filter) (*LogsResponse, *ErrorResponse)
+}
+
+type AuditHandler struct {
+	service AuditServiceInterface
+}
+
+func NewAuditHandler(service AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+// @Summary      List audit logs
+// @Description  Get audit log entries, newest first
+// @Tags         admin
+// @Produce      json
+// @Param        actor_id     query     int     false  "Actor user ID"
+// @Param        action       query     string  false  "Action"
+// @Param        target_type  query     string  false  "Target type"
+// @Param        target_id    query     int     false  "Target ID"
+// @Param        limit        query     int     false  "Page size, 50 by default and 200 at most"
+// @Param        offset       query     int     false  "Number of entries to skip"
+// @Success      200          {object}  LogsResponseSwagger
+// @Failure      400          {object}  ErrorResponse
+// @Failure      500          {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/audit-logs [get]
+func (ah *AuditHandler) ListLogs(c *gin.Context) {
+	var filter Filter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	logs, err := ah.service.ListLogs(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type Entry struct {
+	ID         int64           `json:"id" db:"id"`
+	ActorID    *int            `json:"actor_id,omitempty" db:"actor_id"`
+	Action     string          `json:"action" db:"action"`
+	TargetType string          `json:"target_type,omitempty" db:"target_type"`
+	TargetID   *int            `json:"target_id,omitempty" db:"target_id"`
+	IP         string          `json:"ip,omitempty" db:"ip"`
+	Details    json.RawMessage `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+type Filter struct {
+	ActorID    int    `form:"actor_id"`
+	Action     string `form:"action"`
+	TargetType string `form:"target_type"`
+	TargetID   int    `form:"target_id"`
+	Limit      int    `form:"limit"`
+	Offset     int    `form:"offset"`
+}
+
+type LogsResponse struct {
+	Logs []Entry `json:"logs"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+// Only  for Swagger
+type EntrySwagger struct {
+	ID         int64                  `json:"id"`
+	ActorID    *int                   `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   *int                   `json:"target_id,omitempty"`
+	IP         string                 `json:"ip,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type LogsResponseSwagger struct {
+	Logs []EntrySwagger `json:"logs"`
+}
+
+func (f *Filter) normalize() {
+	if f.Limit <= 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
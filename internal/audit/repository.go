@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const entryColumns = "id, actor_id, action, target_type, target_id, ip, details, created_at"
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) CreateEntry(ctx context.Context, entry *Entry) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO audit_logs (actor_id, action, target_type, target_id, ip, details) VALUES ($1, $2, $3, $4, $5, $6)",
+		entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.IP, entry.Details,
+	)
+	if err != nil {
+		pr.logger.Error("failed to create audit log entry",
+			slog.String("action", entry.Action),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create audit log entry: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) ListEntries(ctx context.Context, filter *Filter) ([]Entry, error) {
+	entries := []Entry{}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.ActorID != 0 {
+		addCondition("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		addCondition("target_id = ?", filter.TargetID)
+	}
+
+	query := "SELECT " + entryColumns + " FROM audit_logs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	err := pr.db.SelectContext(ctx, &entries, query, args...)
+	if err != nil {
+		pr.logger.Error("failed to list audit log entries",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list audit log entries: %w", err)
+	}
+
+	return entries, nil
+}
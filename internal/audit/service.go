@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+type RepositoryInterface interface {
+	CreateEntry(ctx context.Context, entry *Entry) error
+	ListEntries(ctx context.Context, filter *Filter) ([]Entry, error)
+}
+
+type AuditService struct {
+	repo   RepositoryInterface
+	logger *slog.Logger
+}
+
+func NewAuditService(repository RepositoryInterface, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+// Record writes an entry to the audit log. Failing to write the entry
+// doesn't fail the audited action, so errors are only logged.
+func (as *AuditService) Record(ctx context.Context, entry *Entry) {
+	if err := as.repo.CreateEntry(ctx, entry); err != nil {
+		as.logger.Error("audit log entry lost",
+			slog.String("action", entry.Action),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (as *AuditService) ListLogs(ctx context.Context, filter *Filter) (*LogsResponse, *ErrorResponse) {
+	filter.normalize()
+
+	entries, err := as.repo.ListEntries(ctx, filter)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &LogsResponse{Logs: entries}, nil
+}
+
+// NewDetails encodes details for an entry. Values that can't be encoded are
+// dropped rather than failing the audited action.
+func NewDetails(details map[string]interface{}) json.RawMessage {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,9 +11,33 @@ import (
 type AuthServiceInterface interface {
 	CreateUser(ctx context.Context, body *RegisterReqBody) (*IDResponse, *ErrorResponse)
 	LogoutUser(ctx context.Context, refreshToken string) (*StatusResponse, *ErrorResponse)
-	LoginUser(ctx context.Context, body *LoginReqBody) (*TokenResponse, *ErrorResponse)
-	GenerateTokens(ctx context.Context, userID int, oldRefreshToken string) (*TokenResponse, *ErrorResponse)
-	GetUserIDByRefreshToken(ctx context.Context, refreshToken string) (int, *ErrorResponse)
+	LoginUser(ctx context.Context, body *LoginReqBody, client *ClientInfo) (*LoginResponse, *ErrorResponse)
+	VerifyMFA(ctx context.Context, body *VerifyMFAReqBody, client *ClientInfo) (*TokenResponse, *ErrorResponse)
+	RequestOTP(ctx context.Context, body *OTPRequestReqBody) (*StatusResponse, *ErrorResponse)
+	VerifyOTP(ctx context.Context, body *OTPVerifyReqBody, client *ClientInfo) (*LoginResponse, *ErrorResponse)
+	StartOIDCLogin(ctx context.Context, provider, deviceName string) (string, *ErrorResponse)
+	FinishOIDCLogin(ctx context.Context, provider string, query *OIDCCallbackQuery, client *ClientInfo) (*LoginResponse, *ErrorResponse)
+	GenerateTokens(ctx context.Context, oldRefreshToken string, client *ClientInfo) (*TokenResponse, *ErrorResponse)
+	ListUsers(ctx context.Context, filter *UsersFilter) (*UsersResponse, *ErrorResponse)
+	GetUser(ctx context.Context, userID int) (*UserSummary, *ErrorResponse)
+	SuspendUser(ctx context.Context, userID, adminID int) (*UserSummary, *ErrorResponse)
+	UnsuspendUser(ctx context.Context, userID, adminID int) (*UserSummary, *ErrorResponse)
+	VerifyEmail(ctx context.Context, body *VerifyEmailReqBody) (*StatusResponse, *ErrorResponse)
+	ResendVerification(ctx context.Context, body *ResendVerificationReqBody) (*StatusResponse, *ErrorResponse)
+	ForgotPassword(ctx context.Context, body *ForgotPasswordReqBody) (*StatusResponse, *ErrorResponse)
+	ResetPassword(ctx context.Context, body *ResetPasswordReqBody) (*StatusResponse, *ErrorResponse)
+	ChangePassword(ctx context.Context, userID int, body *ChangePasswordReqBody) (*StatusResponse, *ErrorResponse)
+	JWKS() *JWKSet
+	EnrollMFA(ctx context.Context, userID int) (*MFAEnrollResponse, *ErrorResponse)
+	EnableMFA(ctx context.Context, userID int, sessionID string, body *MFACodeReqBody) (*RecoveryCodesResponse, *ErrorResponse)
+	DisableMFA(ctx context.Context, userID int, body *DisableMFAReqBody, ip string) (*StatusResponse, *ErrorResponse)
+	RegenerateRecoveryCodes(ctx context.Context, userID int, body *MFACodeReqBody, ip string) (*RecoveryCodesResponse, *ErrorResponse)
+	GetMFAPolicy(ctx context.Context) (*MFAPolicy, *ErrorResponse)
+	SetMFAPolicy(ctx context.Context, policy *MFAPolicy) (*MFAPolicy, *ErrorResponse)
+	ListSessions(ctx context.Context, userID int, currentSessionID string) (*SessionsResponse, *ErrorResponse)
+	ListUserSessions(ctx context.Context, userID int) (*SessionsResponse, *ErrorResponse)
+	RevokeSession(ctx context.Context, userID int, sessionID string) (*StatusResponse, *ErrorResponse)
+	RevokeAllSessions(ctx context.Context, userID int) (*StatusResponse, *ErrorResponse)
 }
 
 type AuthHandler struct {
@@ -26,13 +51,14 @@ func NewAuthHandler(service AuthServiceInterface) *AuthHandler {
 }
 
 // @Summary      Register new user
-// @Description  Create a new user account
+// @Description  Create a new user account with the USER role. Drivers apply through /drivers/application afterwards
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        body  body      RegisterReqBody  true  "User registration info"
 // @Success      200   {object}  IDResponse
 // @Failure      400   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
 // @Failure      500   {object}  ErrorResponse
 // @Router       /auth/register [post]
 func (ah *AuthHandler) Register(c *gin.Context) {
@@ -45,7 +71,7 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 
 	id, err := ah.service.CreateUser(c, &body)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Message)
+		c.AbortWithStatusJSON(err.StatusCode(http.StatusInternalServerError), err)
 		return
 	}
 
@@ -53,13 +79,16 @@ func (ah *AuthHandler) Register(c *gin.Context) {
 }
 
 // @Summary      Login user
-// @Description  Authenticate user and return access + refresh tokens
+// @Description  Authenticate user and return access + refresh tokens. Accounts with two-factor authentication get mfa_required and an mfa_token for /auth/mfa/verify instead
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        body  body      LoginReqBody  true  "Login credentials"
-// @Success      200   {object}  TokenResponse
+// @Success      200   {object}  LoginResponse
 // @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      429   {object}  ErrorResponse
 // @Failure      500   {object}  ErrorResponse
 // @Router       /auth/login [post]
 func (ah *AuthHandler) Login(c *gin.Context) {
@@ -70,17 +99,262 @@ func (ah *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	tokens, err := ah.service.LoginUser(c, &body)
+	tokens, err := ah.service.LoginUser(c, &body, NewClientInfo(body.DeviceName, c.Request.UserAgent(), c.ClientIP()))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary      Request login code
+// @Description  Send a one-time login code by SMS to the phone of an account. The response doesn't reveal whether the phone is registered
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      OTPRequestReqBody  true  "Phone in E.164 format"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      429   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/otp/request [post]
+func (ah *AuthHandler) RequestOTP(c *gin.Context) {
+	var body OTPRequestReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ah.service.RequestOTP(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Login with code
+// @Description  Exchange a login code sent by SMS for tokens. Accounts with two-factor authentication get mfa_required and an mfa_token instead, like on /auth/login
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      OTPVerifyReqBody  true  "Phone and code"
+// @Success      200   {object}  LoginResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/otp/verify [post]
+func (ah *AuthHandler) VerifyOTP(c *gin.Context) {
+	var body OTPVerifyReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	tokens, err := ah.service.VerifyOTP(c, &body, NewClientInfo(body.DeviceName, c.Request.UserAgent(), c.ClientIP()))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary      Login with a provider
+// @Description  Redirect to an OpenID Connect provider (authorization code flow with PKCE). The provider redirects back to the configured redirect URL, which passes code and state to /auth/oidc/{provider}/callback
+// @Tags         auth
+// @Param        provider     path   string  true   "Provider name"
+// @Param        device_name  query  string  false  "Label shown in the list of sessions"
+// @Success      302
+// @Failure      404  {object}  ErrorResponse
+// @Failure      502  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /auth/oidc/{provider}/authorize [get]
+func (ah *AuthHandler) StartOIDCLogin(c *gin.Context) {
+	authURL, err := ah.service.StartOIDCLogin(c, c.Param("provider"), c.Query("device_name"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary      Finish provider login
+// @Description  Exchange the code from the provider for tokens. The user is matched by the linked provider account, then by a verified email, otherwise a USER account is created. Accounts with two-factor authentication get mfa_required and an mfa_token, like on /auth/login
+// @Tags         auth
+// @Produce      json
+// @Param        provider  path      string  true   "Provider name"
+// @Param        code      query     string  false  "Authorization code"
+// @Param        state     query     string  true   "State from the authorize redirect"
+// @Param        error     query     string  false  "Error reported by the provider"
+// @Success      200       {object}  LoginResponse
+// @Failure      400       {object}  ErrorResponse
+// @Failure      401       {object}  ErrorResponse
+// @Failure      403       {object}  ErrorResponse
+// @Failure      404       {object}  ErrorResponse
+// @Failure      409       {object}  ErrorResponse
+// @Failure      500       {object}  ErrorResponse
+// @Router       /auth/oidc/{provider}/callback [get]
+func (ah *AuthHandler) FinishOIDCLogin(c *gin.Context) {
+	var query OIDCCallbackQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	tokens, err := ah.service.FinishOIDCLogin(c, c.Param("provider"), &query, NewClientInfo("", c.Request.UserAgent(), c.ClientIP()))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary      Verify two-factor code
+// @Description  Complete a login with a TOTP code or a recovery code
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      VerifyMFAReqBody  true  "Challenge token and code"
+// @Success      200   {object}  TokenResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      429   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/mfa/verify [post]
+func (ah *AuthHandler) VerifyMFA(c *gin.Context) {
+	var body VerifyMFAReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	tokens, err := ah.service.VerifyMFA(c, &body, NewClientInfo("", c.Request.UserAgent(), c.ClientIP()))
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Message)
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
 		return
 	}
 
 	c.JSON(http.StatusOK, tokens)
 }
 
+// @Summary      Enroll in two-factor authentication
+// @Description  Generate a TOTP secret and the otpauth:// URI for authenticator apps. Confirm it with /auth/mfa/enable
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  MFAEnrollResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/mfa/enroll [post]
+func (ah *AuthHandler) EnrollMFA(c *gin.Context) {
+	enrollment, err := ah.service.EnrollMFA(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary      Enable two-factor authentication
+// @Description  Confirm enrollment with a code from the authenticator app. Returns recovery codes, which are shown only once
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      MFACodeReqBody  true  "TOTP code"
+// @Success      200   {object}  RecoveryCodesResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/mfa/enable [post]
+func (ah *AuthHandler) EnableMFA(c *gin.Context) {
+	var body MFACodeReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	codes, err := ah.service.EnableMFA(c, c.GetInt("userID"), c.GetString("sessionID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+// @Summary      Disable two-factor authentication
+// @Description  Turn two-factor authentication off with the password and a TOTP or recovery code. Not allowed for roles where it is mandatory
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      DisableMFAReqBody  true  "Password and code"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      429   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/mfa/disable [post]
+func (ah *AuthHandler) DisableMFA(c *gin.Context) {
+	var body DisableMFAReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ah.service.DisableMFA(c, c.GetInt("userID"), &body, c.ClientIP())
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Regenerate recovery codes
+// @Description  Replace all recovery codes. Needs a TOTP code
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      MFACodeReqBody  true  "TOTP code"
+// @Success      200   {object}  RecoveryCodesResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      429   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/mfa/recovery-codes [post]
+func (ah *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var body MFACodeReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	codes, err := ah.service.RegenerateRecoveryCodes(c, c.GetInt("userID"), &body, c.ClientIP())
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
 // @Summary      Logout user
-// @Description  Delete refresh token
+// @Description  End the session the refresh token belongs to
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -105,13 +379,14 @@ func (ah *AuthHandler) Logout(c *gin.Context) {
 }
 
 // @Summary      Refresh JWT tokens
-// @Description  Generate new tokens
+// @Description  Exchange a refresh token for a new pair. The refresh token is single-use: presenting it again revokes the whole session
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        body  body      LogoutReqBody  true  "Old refresh token"
 // @Success  	 200   {object}  TokenResponse
 // @Failure      401   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
 // @Router       /auth/refresh [post]
 func (ah *AuthHandler) RefreshToken(c *gin.Context) {
 	var body LogoutReqBody
@@ -121,22 +396,442 @@ func (ah *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	userID, err := ah.service.GetUserIDByRefreshToken(c, body.RefreshToken)
+	tokens, err := ah.service.GenerateTokens(c, body.RefreshToken, NewClientInfo("", c.Request.UserAgent(), c.ClientIP()))
 	if err != nil {
-		newErrorResponse(c, http.StatusUnauthorized, "invalid refresh token")
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary      Verify email
+// @Description  Confirm the email address with the token from the verification email
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      VerifyEmailReqBody  true  "Verification token"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/verify-email [post]
+func (ah *AuthHandler) VerifyEmail(c *gin.Context) {
+	var body VerifyEmailReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
 		return
 	}
 
-	tokens, errResp := ah.service.GenerateTokens(c, userID, body.RefreshToken)
-	if errResp != nil {
-		newErrorResponse(c, http.StatusUnauthorized, errResp.Message)
+	status, err := ah.service.VerifyEmail(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
 		return
 	}
+	c.JSON(http.StatusOK, status)
+}
 
-	c.JSON(http.StatusOK, tokens)
+// @Summary      Resend verification email
+// @Description  Send a new verification email. The response doesn't reveal whether the email is registered
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ResendVerificationReqBody  true  "Email"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/verify-email/resend [post]
+func (ah *AuthHandler) ResendVerification(c *gin.Context) {
+	var body ResendVerificationReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
 
+	status, err := ah.service.ResendVerification(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Forgot password
+// @Description  Email a single-use password reset token. The response doesn't reveal whether the email is registered
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ForgotPasswordReqBody  true  "Email"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/password/forgot [post]
+func (ah *AuthHandler) ForgotPassword(c *gin.Context) {
+	var body ForgotPasswordReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ah.service.ForgotPassword(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Reset password
+// @Description  Set a new password with the token from the reset email. Signs the user out on all devices
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ResetPasswordReqBody  true  "Reset token and new password"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /auth/password/reset [post]
+func (ah *AuthHandler) ResetPassword(c *gin.Context) {
+	var body ResetPasswordReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ah.service.ResetPassword(c, &body)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode(http.StatusInternalServerError), err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Change password
+// @Description  Change the password of the current user. Signs the user out on all devices
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ChangePasswordReqBody  true  "Current and new password"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/password/change [post]
+func (ah *AuthHandler) ChangePassword(c *gin.Context) {
+	var body ChangePasswordReqBody
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ah.service.ChangePassword(c, c.GetInt("userID"), &body)
+	if err != nil {
+		c.AbortWithStatusJSON(err.StatusCode(http.StatusInternalServerError), err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying access tokens. Empty when tokens are signed with a shared secret
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (ah *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ah.service.JWKS())
+}
+
+// @Summary      List sessions
+// @Description  List the devices the current user is logged in on
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  SessionsResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/sessions [get]
+func (ah *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := ah.service.ListSessions(c, c.GetInt("userID"), c.GetString("sessionID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// @Summary      Revoke session
+// @Description  Log the current user out on one device
+// @Tags         auth
+// @Produce      json
+// @Param        id   path      string  true  "Session ID"
+// @Success      200  {object}  StatusResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/sessions/{id} [delete]
+func (ah *AuthHandler) RevokeSession(c *gin.Context) {
+	status, err := ah.service.RevokeSession(c, c.GetInt("userID"), c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Logout everywhere
+// @Description  End every session of the current user, including this one
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  StatusResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /auth/logout-all [post]
+func (ah *AuthHandler) LogoutAll(c *gin.Context) {
+	status, err := ah.service.RevokeAllSessions(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      List users
+// @Description  Search users by name or email, role and suspension
+// @Tags         admin
+// @Produce      json
+// @Param        q          query     string  false  "Part of the name or email"
+// @Param        role       query     string  false  "Role"  Enums(USER, DRIVER, ADMIN, SUPPORT, DISPATCHER)
+// @Param        suspended  query     bool    false  "Only suspended or only active users"
+// @Param        limit      query     int     false  "Page size, 50 by default and 200 at most"
+// @Param        offset     query     int     false  "Number of users to skip"
+// @Success      200        {object}  UsersResponse
+// @Failure      400        {object}  ErrorResponse
+// @Failure      500        {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users [get]
+func (ah *AuthHandler) ListUsers(c *gin.Context) {
+	var filter UsersFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	users, err := ah.service.ListUsers(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// @Summary      Get user
+// @Description  Get a user by ID
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  UserSummary
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id} [get]
+func (ah *AuthHandler) GetUser(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	user, err := ah.service.GetUser(c, userID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary      Suspend user
+// @Description  Suspend an account. The user can't log in or refresh tokens until reinstated
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  UserSummary
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id}/suspend [post]
+func (ah *AuthHandler) SuspendUser(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	user, err := ah.service.SuspendUser(c, userID, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary      Unsuspend user
+// @Description  Reinstate a suspended account
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  UserSummary
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id}/unsuspend [post]
+func (ah *AuthHandler) UnsuspendUser(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	user, err := ah.service.UnsuspendUser(c, userID, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary      List user sessions
+// @Description  List the devices a user is logged in on
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  SessionsResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id}/sessions [get]
+func (ah *AuthHandler) ListUserSessions(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	sessions, err := ah.service.ListUserSessions(c, userID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// @Summary      Revoke user session
+// @Description  Log a user out on one device
+// @Tags         admin
+// @Produce      json
+// @Param        id         path      int     true  "User ID"
+// @Param        sessionID  path      string  true  "Session ID"
+// @Success      200        {object}  StatusResponse
+// @Failure      400        {object}  ErrorResponse
+// @Failure      404        {object}  ErrorResponse
+// @Failure      500        {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id}/sessions/{sessionID} [delete]
+func (ah *AuthHandler) RevokeUserSession(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	status, err := ah.service.RevokeSession(c, userID, c.Param("sessionID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Logout user everywhere
+// @Description  End every session of a user
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  StatusResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/users/{id}/logout-all [post]
+func (ah *AuthHandler) LogoutUserEverywhere(c *gin.Context) {
+	userID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	status, err := ah.service.RevokeAllSessions(c, userID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Get two-factor policy
+// @Description  List the roles that must use two-factor authentication
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  MFAPolicy
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/mfa-policy [get]
+func (ah *AuthHandler) GetMFAPolicy(c *gin.Context) {
+	policy, err := ah.service.GetMFAPolicy(c)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// @Summary      Set two-factor policy
+// @Description  Replace the roles that must use two-factor authentication. Their users without it can only enroll until they turn it on
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      MFAPolicy  true  "Roles"
+// @Success      200   {object}  MFAPolicy
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/mfa-policy [put]
+func (ah *AuthHandler) SetMFAPolicy(c *gin.Context) {
+	var body MFAPolicy
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	policy, err := ah.service.SetMFAPolicy(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
 }
 
 func newErrorResponse(c *gin.Context, statusCode int, message string) {
-	c.AbortWithStatusJSON(statusCode, ErrorResponse{message})
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
 }
@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	recoveryCodeCount       = 10
+)
+
+var (
+	errMFANotEnrolled    = errors.New("two-factor authentication is not set up")
+	errMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	errMFAMandatory      = errors.New("two-factor authentication is mandatory for your role")
+	errInvalidMFACode    = errors.New("invalid two-factor code")
+	errInvalidRole       = errors.New("unknown role")
+)
+
+// startMFAChallenge answers a correct password of an account with two-factor
+// authentication. The challenge token is exchanged for tokens at VerifyMFA.
+func (as *AuthService) startMFAChallenge(ctx context.Context, userID int, client *ClientInfo) (*LoginResponse, *ErrorResponse) {
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	var deviceName *string
+	if client.DeviceName != "" {
+		deviceName = &client.DeviceName
+	}
+
+	err = as.repo.CreateMFAChallenge(ctx, userID, hashToken(token), deviceName, time.Now().Add(mfaChallengeTTL))
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	return &LoginResponse{MFARequired: true, MFAToken: token}, nil
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code.
+func (as *AuthService) VerifyMFA(ctx context.Context, body *VerifyMFAReqBody, client *ClientInfo) (*TokenResponse, *ErrorResponse) {
+	tokenHash := hashToken(strings.TrimSpace(body.MFAToken))
+
+	challenge, err := as.repo.UseMFAChallengeAttempt(ctx, tokenHash, mfaChallengeMaxAttempts)
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	user, err := as.repo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if user.SuspendedAt != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusForbidden, errAccountSuspended)
+	}
+
+	if errResp := as.checkMFACode(ctx, user.ID, body.Code, true, client.IP); errResp != nil {
+		return nil, errResp
+	}
+
+	if err := as.repo.DeleteMFAChallenge(ctx, tokenHash); err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if challenge.DeviceName != nil {
+		client.DeviceName = *challenge.DeviceName
+	}
+
+	tokens, errResp := as.openSession(ctx, user.ID, user.Role, user.TokenVersion, client, true)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	as.logger.Info("user loged in with two-factor authentication",
+		slog.Int("user_id", user.ID),
+	)
+
+	return tokens, nil
+}
+
+// EnrollMFA generates a new secret. Two-factor authentication is turned on
+// only once a code from it is confirmed with EnableMFA.
+func (as *AuthService) EnrollMFA(ctx context.Context, userID int) (*MFAEnrollResponse, *ErrorResponse) {
+	user, err := as.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if err := as.repo.SaveMFASecret(ctx, userID, secret); err != nil {
+		return nil, mfaErrorResponse(err)
+	}
+
+	return &MFAEnrollResponse{Secret: secret, ProvisioningURI: totpURI(secret, user.Email)}, nil
+}
+
+// EnableMFA confirms enrollment with a code and returns the recovery codes.
+// The current session counts as verified from then on.
+func (as *AuthService) EnableMFA(ctx context.Context, userID int, sessionID string, body *MFACodeReqBody) (*RecoveryCodesResponse, *ErrorResponse) {
+	mfa, err := as.repo.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, mfaErrorResponse(err)
+	}
+	if mfa.EnabledAt != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusConflict, errMFAAlreadyEnabled)
+	}
+
+	counter, ok := validateTOTP(mfa.Secret, normalizeMFACode(body.Code), time.Now())
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidMFACode)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if err := as.repo.EnableMFA(ctx, userID, counter, hashes); err != nil {
+		return nil, mfaErrorResponse(err)
+	}
+
+	if sessionID != "" {
+		if err := as.repo.MarkSessionMFA(ctx, userID, sessionID); err != nil {
+			return nil, NewErrorResponse(err)
+		}
+	}
+
+	as.logger.Info("two-factor authentication enabled",
+		slog.Int("user_id", userID),
+	)
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns two-factor authentication off. It needs the password and a
+// code, and isn't allowed for roles that must use two-factor authentication.
+func (as *AuthService) DisableMFA(ctx context.Context, userID int, body *DisableMFAReqBody, ip string) (*StatusResponse, *ErrorResponse) {
+	user, err := as.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+
+	required, err := as.MFARequired(ctx, user.Role)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	if required {
+		return nil, NewErrorResponseWithStatus(http.StatusConflict, errMFAMandatory)
+	}
+
+	passwordHash, err := as.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+	if !checkPassword(passwordHash, body.Password) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errWrongPassword)
+	}
+
+	if errResp := as.checkMFACode(ctx, userID, body.Code, true, ip); errResp != nil {
+		return nil, errResp
+	}
+
+	if err := as.repo.DisableMFA(ctx, userID); err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	as.logger.Info("two-factor authentication disabled",
+		slog.Int("user_id", userID),
+	)
+
+	return NewStatusResponse("two-factor authentication disabled"), nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. It takes a TOTP code,
+// so a leaked recovery code can't be turned into a fresh set.
+func (as *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int, body *MFACodeReqBody, ip string) (*RecoveryCodesResponse, *ErrorResponse) {
+	if errResp := as.checkMFACode(ctx, userID, body.Code, false, ip); errResp != nil {
+		return nil, errResp
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if err := as.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// checkMFACode accepts a TOTP code of the enabled secret that hasn't been
+// used yet and, if allowRecovery is set, an unused recovery code. Wrong codes
+// count towards a per-user lockout with the same limits as logins.
+func (as *AuthService) checkMFACode(ctx context.Context, userID int, code string, allowRecovery bool, ip string) *ErrorResponse {
+	if errResp := as.checkMFALock(ctx, userID); errResp != nil {
+		return errResp
+	}
+
+	mfa, err := as.repo.GetMFA(ctx, userID)
+	if err != nil {
+		return mfaErrorResponse(err)
+	}
+	if mfa.EnabledAt == nil {
+		return NewErrorResponseWithStatus(http.StatusBadRequest, errMFANotEnrolled)
+	}
+
+	code = normalizeMFACode(code)
+
+	var ok bool
+	if counter, valid := validateTOTP(mfa.Secret, code, time.Now()); valid {
+		ok, err = as.repo.UseTOTPCounter(ctx, userID, counter)
+	} else if allowRecovery && len(code) > totpDigits {
+		ok, err = as.repo.UseRecoveryCode(ctx, userID, hashToken(code))
+		if ok {
+			as.logger.Info("recovery code used",
+				slog.Int("user_id", userID),
+			)
+		}
+	}
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+	if !ok {
+		as.recordAttempt(ctx, mfaAttemptKey(userID), "mfa", as.cfg.LoginThrottle.MaxAttempts, "", ip, userID)
+		return NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidMFACode)
+	}
+
+	if err := as.repo.ResetLoginAttempts(ctx, mfaAttemptKey(userID)); err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return nil
+}
+
+// MFARequired reports whether the role must use two-factor authentication.
+// The policy is cached like token versions.
+func (as *AuthService) MFARequired(ctx context.Context, role string) (bool, error) {
+	roles, ok := as.policy.get()
+	if !ok {
+		list, err := as.repo.GetMFARequiredRoles(ctx)
+		if err != nil {
+			return false, err
+		}
+		roles = as.policy.set(list)
+	}
+	return roles[role], nil
+}
+
+func (as *AuthService) GetMFAPolicy(ctx context.Context) (*MFAPolicy, *ErrorResponse) {
+	roles, err := as.repo.GetMFARequiredRoles(ctx)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &MFAPolicy{Roles: roles}, nil
+}
+
+// SetMFAPolicy replaces the roles that must use two-factor authentication.
+// Their users who haven't enrolled can still log in, but only to enroll.
+func (as *AuthService) SetMFAPolicy(ctx context.Context, policy *MFAPolicy) (*MFAPolicy, *ErrorResponse) {
+	roles := make([]string, 0, len(policy.Roles))
+	seen := make(map[string]bool)
+	for _, role := range policy.Roles {
+		role = strings.ToUpper(strings.TrimSpace(role))
+		if !rbac.IsRole(role) {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidRole)
+		}
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	if err := as.repo.SetMFARequiredRoles(ctx, roles); err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	as.policy.set(roles)
+
+	return as.GetMFAPolicy(ctx)
+}
+
+func mfaErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, errMFANotEnrolled):
+		return NewErrorResponseWithStatus(http.StatusBadRequest, err)
+	case errors.Is(err, errMFAAlreadyEnabled):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	case errors.Is(err, ErrUserNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	}
+	return NewErrorResponse(err)
+}
+
+// normalizeMFACode drops the spaces and dashes people type into codes.
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// newRecoveryCodes returns codes formatted for the user and the hashes to
+// store. Recovery codes are checked with normalizeMFACode applied.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+type mfaPolicyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	roles     map[string]bool
+	expiresAt time.Time
+}
+
+func (pc *mfaPolicyCache) get() (map[string]bool, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.roles == nil || time.Now().After(pc.expiresAt) {
+		return nil, false
+	}
+	return pc.roles, true
+}
+
+func (pc *mfaPolicyCache) set(list []string) map[string]bool {
+	roles := make(map[string]bool, len(list))
+	for _, role := range list {
+		roles[role] = true
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.roles = roles
+	pc.expiresAt = time.Now().Add(pc.ttl)
+	return roles
+}
@@ -1,27 +1,178 @@
 package auth
 
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+const (
+	VerificationForLogin = "login"
+	VerificationForRides = "rides"
+)
+
+// User is the stored account, including the password hash. It must not be
+// returned to clients; the profile package has the view for that.
 type User struct {
-	ID       int    `json:"id" db:"id"`
-	Name     string `json:"name" db:"name"`
-	Email    string `json:"email" db:"email"`
-	Password string `json:"password" db:"password_hash"`
-	Role     string `json:"role" db:"role"`
+	ID              int        `json:"id" db:"id"`
+	Name            string     `json:"name" db:"name"`
+	Email           string     `json:"email" db:"email"`
+	Password        string     `json:"-" db:"password_hash"`
+	Role            string     `json:"role" db:"role"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty" db:"suspended_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	Phone           *string    `json:"phone,omitempty" db:"phone"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
+	TokenVersion    int        `json:"-" db:"token_version"`
+}
+
+// UserSummary is the view of a user returned by the admin API.
+type UserSummary struct {
+	ID              int        `json:"id" db:"id"`
+	Name            string     `json:"name" db:"name"`
+	Email           string     `json:"email" db:"email"`
+	Role            string     `json:"role" db:"role"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty" db:"suspended_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	Phone           *string    `json:"phone,omitempty" db:"phone"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
+	TokenVersion    int        `json:"-" db:"token_version"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+type UsersFilter struct {
+	Query     string `form:"q"`
+	Role      string `form:"role"`
+	Suspended *bool  `form:"suspended"`
+	Limit     int    `form:"limit"`
+	Offset    int    `form:"offset"`
+}
+
+type UsersResponse struct {
+	Users []UserSummary `json:"users"`
+}
+
+// Config holds the settings AuthService needs from the application config.
+type Config struct {
+	JWTSecret string
+	// SigningKeys, when set, replaces JWTSecret for signing access tokens.
+	SigningKeys *KeySet
+	// AcceptLegacyHS256Until keeps accepting HS256 tokens signed with
+	// JWTSecret next to SigningKeys until then.
+	AcceptLegacyHS256Until time.Time
+	// Issuer and Audience go into the iss and aud claims and are required
+	// when verifying. Leeway is the clock skew allowed for exp, nbf and iat.
+	Issuer          string
+	Audience        string
+	Leeway          time.Duration
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// TokenVersionCacheTTL bounds how long another instance may keep
+	// accepting revoked access tokens.
+	TokenVersionCacheTTL time.Duration
+
+	VerificationTokenTTL time.Duration
+	// RequireVerifiedEmail is VerificationForLogin, VerificationForRides or
+	// empty when unverified users aren't restricted.
+	RequireVerifiedEmail string
+	// VerificationURL, when set, is sent as a link with the token appended
+	// as the token query parameter.
+	VerificationURL string
+
+	LoginThrottle LoginThrottle
+
+	PasswordResetTokenTTL time.Duration
+	// PasswordResetURL works like VerificationURL for password reset emails.
+	PasswordResetURL string
+
+	OTP OTPConfig
+
+	// OIDCProviders are the OpenID Connect providers users can log in with,
+	// by name.
+	OIDCProviders map[string]OIDCProvider
+	// OIDCStateTTL is how long a login started with a provider may take.
+	OIDCStateTTL time.Duration
 }
 
 type IDResponse struct {
 	ID int `json:"id"`
 }
 
+// RegisterReqBody has no role: every account starts as USER and becomes
+// DRIVER once its driver application is approved.
 type RegisterReqBody struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
-	Role     string `json:"role"`
+	// Phone is optional and enables login with a code sent by SMS.
+	Phone string `json:"phone,omitempty" example:"+992900123456"`
+}
+
+type VerifyEmailReqBody struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationReqBody struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordReqBody struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordReqBody struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type ChangePasswordReqBody struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
 
 type LoginReqBody struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// DeviceName is an optional label shown in the list of sessions.
+	DeviceName string `json:"device_name"`
+}
+
+type OTPRequestReqBody struct {
+	Phone string `json:"phone" example:"+992900123456"`
+}
+
+type OTPVerifyReqBody struct {
+	Phone      string `json:"phone" example:"+992900123456"`
+	Code       string `json:"code"`
+	DeviceName string `json:"device_name"`
+}
+
+// ClientInfo describes the client a session was opened or last used from.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
+
+// Session is a login on one device. It lives as long as its refresh tokens
+// keep being rotated.
+type Session struct {
+	ID         string    `json:"id" db:"id"`
+	DeviceName *string   `json:"device_name,omitempty" db:"device_name"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	Current    bool      `json:"current" db:"-"`
+}
+
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
 }
 
 type LogoutReqBody struct {
@@ -33,12 +184,113 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// LoginResponse carries either the tokens or, for accounts with two-factor
+// authentication, a challenge token to complete at /auth/mfa/verify.
+type LoginResponse struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type VerifyMFAReqBody struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a TOTP code or one of the recovery codes.
+	Code string `json:"code"`
+}
+
+type MFACodeReqBody struct {
+	Code string `json:"code"`
+}
+
+type DisableMFAReqBody struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAPolicy lists the roles that must use two-factor authentication.
+type MFAPolicy struct {
+	Roles []string `json:"roles"`
+}
+
+type userMFA struct {
+	Secret      string     `db:"secret"`
+	EnabledAt   *time.Time `db:"enabled_at"`
+	LastCounter int64      `db:"last_counter"`
+}
+
+// OIDCCallbackQuery is what the provider appends to the redirect URL.
+type OIDCCallbackQuery struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+type oidcState struct {
+	Provider     string  `db:"provider"`
+	Nonce        string  `db:"nonce"`
+	CodeVerifier string  `db:"code_verifier"`
+	DeviceName   *string `db:"device_name"`
+}
+
+type mfaChallenge struct {
+	UserID     int     `db:"user_id"`
+	DeviceName *string `db:"device_name"`
+}
+
+// sessionRef identifies the session a refresh token belongs to.
+type sessionRef struct {
+	UserID    int
+	SessionID string
+	MFA       bool
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
 type StatusResponse struct {
 	Status string `json:"status"`
 }
 
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	status  int
+}
+
+func (f *UsersFilter) normalize() {
+	if f.Limit <= 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
 }
 
 func NewErrorResponse(err error) *ErrorResponse {
@@ -47,6 +299,28 @@ func NewErrorResponse(err error) *ErrorResponse {
 	}
 }
 
+func NewValidationErrorResponse(fields []FieldError) *ErrorResponse {
+	return &ErrorResponse{
+		Message: "validation failed",
+		Fields:  fields,
+		status:  http.StatusBadRequest,
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
+
 func NewStatusResponse(status string) *StatusResponse {
 	return &StatusResponse{
 		Status: status,
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/audit"
+	"github.com/AzizovHikmatullo/go-ride/internal/oidc"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+)
+
+var (
+	errUnknownProvider         = errors.New("unknown login provider")
+	errIdentityLinked          = errors.New("another account of this provider is already linked")
+	errProviderNoEmail         = errors.New("the provider didn't share an email address")
+	errProviderEmailTaken      = errors.New("an account with this email already exists, log in with the password")
+	errProviderEmailUnverified = errors.New("an account with this email already exists, log in with the password and verify the email to link the provider")
+	errProviderLoginFailed     = errors.New("login with the provider failed")
+	errProviderLoginDenied     = errors.New("login with the provider was cancelled")
+	errProviderStateInvalid    = errors.New("login with the provider expired, start again")
+)
+
+// OIDCProvider is an OpenID Connect provider using the authorization code
+// flow with PKCE.
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oidc.Identity, error)
+}
+
+// StartOIDCLogin returns the provider URL to send the user to. The state,
+// nonce and PKCE verifier stay on the server until the callback.
+func (as *AuthService) StartOIDCLogin(ctx context.Context, providerName, deviceName string) (string, *ErrorResponse) {
+	provider, ok := as.cfg.OIDCProviders[providerName]
+	if !ok {
+		return "", NewErrorResponseWithStatus(http.StatusNotFound, errUnknownProvider)
+	}
+
+	state, err := newRandomToken()
+	if err != nil {
+		return "", NewErrorResponse(err)
+	}
+	nonce, err := newRandomToken()
+	if err != nil {
+		return "", NewErrorResponse(err)
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", NewErrorResponse(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		as.logger.Error("failed to build provider login url",
+			slog.String("provider", providerName),
+			slog.String("error", err.Error()),
+		)
+		return "", NewErrorResponseWithStatus(http.StatusBadGateway, errProviderLoginFailed)
+	}
+
+	loginState := &oidcState{Provider: providerName, Nonce: nonce, CodeVerifier: verifier}
+	if deviceName = truncate(strings.TrimSpace(deviceName), maxDeviceNameLength); deviceName != "" {
+		loginState.DeviceName = &deviceName
+	}
+
+	err = as.repo.CreateOIDCState(ctx, hashToken(state), loginState, time.Now().Add(as.cfg.OIDCStateTTL))
+	if err != nil {
+		return "", NewErrorResponse(err)
+	}
+
+	return authURL, nil
+}
+
+// FinishOIDCLogin handles the redirect back from the provider. The user is
+// found by the linked provider account, then by a verified email, which
+// links the provider account to it; otherwise a new USER account is created.
+func (as *AuthService) FinishOIDCLogin(ctx context.Context, providerName string, query *OIDCCallbackQuery, client *ClientInfo) (*LoginResponse, *ErrorResponse) {
+	provider, ok := as.cfg.OIDCProviders[providerName]
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusNotFound, errUnknownProvider)
+	}
+
+	if query.Error != "" {
+		as.logger.Info("provider login failed",
+			slog.String("provider", providerName),
+			slog.String("error", query.Error),
+			slog.String("description", query.ErrorDescription),
+		)
+		return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errProviderLoginDenied)
+	}
+	if query.Code == "" || query.State == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("code and state are required"))
+	}
+
+	state, err := as.repo.TakeOIDCState(ctx, hashToken(query.State), providerName)
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errProviderStateInvalid)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	if state.DeviceName != nil {
+		client.DeviceName = *state.DeviceName
+	}
+
+	identity, err := provider.Exchange(ctx, query.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		as.logger.Warn("failed to redeem provider code",
+			slog.String("provider", providerName),
+			slog.String("error", err.Error()),
+		)
+		return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errProviderLoginFailed)
+	}
+
+	user, errResp := as.resolveOIDCUser(ctx, providerName, identity, client)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	return as.completeLogin(ctx, user, client, "oidc:"+providerName)
+}
+
+func (as *AuthService) resolveOIDCUser(ctx context.Context, providerName string, identity *oidc.Identity, client *ClientInfo) (*User, *ErrorResponse) {
+	user, err := as.repo.GetUserByIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, NewErrorResponse(err)
+	}
+
+	email := normalizeEmail(identity.Email)
+	if !validEmail(email) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errProviderNoEmail)
+	}
+
+	user, err = as.repo.GetUserByEmail(ctx, email)
+	switch {
+	case err == nil:
+		// Linking on an unverified email would let anyone who controls a
+		// provider account with that address take over the user. The local
+		// email has to be verified too, or whoever registered it first
+		// could keep a password on the owner's account.
+		if !identity.EmailVerified {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, errProviderEmailTaken)
+		}
+		if user.EmailVerifiedAt == nil {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, errProviderEmailUnverified)
+		}
+		if err := as.repo.LinkIdentity(ctx, user.ID, providerName, identity.Subject, email); err != nil {
+			if errors.Is(err, errIdentityLinked) {
+				return nil, NewErrorResponseWithStatus(http.StatusConflict, err)
+			}
+			return nil, NewErrorResponse(err)
+		}
+		as.recordIdentityEvent(ctx, "auth.identity_linked", user.ID, providerName, client.IP)
+		return user, nil
+	case !errors.Is(err, ErrUserNotFound):
+		return nil, NewErrorResponse(err)
+	}
+
+	return as.createOIDCUser(ctx, providerName, identity, email, client)
+}
+
+// createOIDCUser signs up a provider user. The account gets a random
+// password nobody knows; the user can set one with a password reset.
+func (as *AuthService) createOIDCUser(ctx context.Context, providerName string, identity *oidc.Identity, email string, client *ClientInfo) (*User, *ErrorResponse) {
+	password, err := newRandomToken()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	passwordHash, err := getPasswordHash(password)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	user := &User{Name: oidcUserName(identity.Name, email), Email: email, Role: rbac.RoleUser}
+
+	id, err := as.repo.CreateOIDCUser(ctx, user, passwordHash, identity.EmailVerified, providerName, identity.Subject)
+	if err != nil {
+		if errors.Is(err, errEmailTaken) {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, errProviderEmailTaken)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	as.logger.Info("user created",
+		slog.Int("user_id", id),
+		slog.String("provider", providerName),
+	)
+	as.recordIdentityEvent(ctx, "auth.identity_signup", id, providerName, client.IP)
+
+	user, err = as.repo.GetUserByIdentity(ctx, providerName, identity.Subject)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return user, nil
+}
+
+func (as *AuthService) recordIdentityEvent(ctx context.Context, action string, userID int, providerName, ip string) {
+	as.audit.Record(ctx, &audit.Entry{
+		ActorID:    &userID,
+		Action:     action,
+		TargetType: "users",
+		TargetID:   &userID,
+		IP:         ip,
+		Details:    audit.NewDetails(map[string]interface{}{"provider": providerName}),
+	})
+}
+
+// oidcUserName uses the name from the provider, falling back to the local
+// part of the email when it is missing or too short.
+func oidcUserName(name, email string) string {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) < MinNameLength {
+		name = email[:strings.Index(email, "@")]
+	}
+	if utf8.RuneCountInString(name) < MinNameLength {
+		name = "Go-Ride user"
+	}
+	return truncate(name, MaxNameLength)
+}
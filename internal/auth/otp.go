@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/sms"
+)
+
+const otpDigits = 6
+
+var (
+	errTooManyOTPRequests = errors.New("too many codes requested, try again later")
+	errInvalidOTP         = errors.New("invalid or expired code")
+)
+
+// OTPConfig configures login codes sent by SMS. A phone gets a new code at
+// most once per ResendInterval and at most MaxSends times within SendWindow.
+// Each code allows MaxAttempts guesses.
+type OTPConfig struct {
+	CodeTTL        time.Duration
+	ResendInterval time.Duration
+	MaxSends       int
+	SendWindow     time.Duration
+	MaxAttempts    int
+}
+
+// RequestOTP sends a login code to the phone. The response is the same
+// whether or not the phone belongs to an account, so it can't be used to
+// find out who is registered: the send limit applies to every number, and a
+// failed SMS is only logged.
+func (as *AuthService) RequestOTP(ctx context.Context, body *OTPRequestReqBody) (*StatusResponse, *ErrorResponse) {
+	response := NewStatusResponse("if the phone number is registered, a code has been sent")
+
+	phone := NormalizePhone(body.Phone)
+	if !ValidPhone(phone) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("phone must be in E.164 format, e.g. +992900123456"))
+	}
+
+	code, err := newOTPCode()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	otp := as.cfg.OTP
+	err = as.repo.CreateOTPCode(ctx, phone, hashToken(code), time.Now().Add(otp.CodeTTL), otp.ResendInterval, otp.SendWindow, otp.MaxSends)
+	if err != nil {
+		if errors.Is(err, errTooManyOTPRequests) {
+			return nil, NewErrorResponseWithStatus(http.StatusTooManyRequests, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	user, err := as.repo.GetUserByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return response, nil
+		}
+		return nil, NewErrorResponse(err)
+	}
+	if user.SuspendedAt != nil {
+		return response, nil
+	}
+
+	err = as.sms.Send(ctx, &sms.Message{
+		To:   phone,
+		Body: fmt.Sprintf("Your Go-Ride login code is %s. It expires in %s.", code, otp.CodeTTL),
+	})
+	if err != nil {
+		as.logger.Error("failed to send login code",
+			slog.Int("user_id", user.ID),
+			slog.String("error", err.Error()),
+		)
+		return response, nil
+	}
+
+	as.logger.Info("login code sent",
+		slog.Int("user_id", user.ID),
+	)
+
+	return response, nil
+}
+
+// VerifyOTP logs in with a code from RequestOTP. The code replaces the
+// password only: accounts with two-factor authentication still get a
+// challenge.
+func (as *AuthService) VerifyOTP(ctx context.Context, body *OTPVerifyReqBody, client *ClientInfo) (*LoginResponse, *ErrorResponse) {
+	phone := NormalizePhone(body.Phone)
+	code := strings.TrimSpace(body.Code)
+	if !ValidPhone(phone) || code == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("phone and code are required"))
+	}
+
+	matched, err := as.repo.UseOTPCode(ctx, phone, hashToken(code), as.cfg.OTP.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidOTP)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	if !matched {
+		return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidOTP)
+	}
+
+	user, err := as.repo.GetUserByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidOTP)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	if user.PhoneVerifiedAt == nil && user.SuspendedAt == nil {
+		if err := as.repo.MarkPhoneVerified(ctx, user.ID); err != nil {
+			return nil, NewErrorResponse(err)
+		}
+	}
+
+	return as.completeLogin(ctx, user, client, "phone")
+}
+
+func newOTPCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/mailer"
+)
+
+// passwordResetCooldown limits how often a reset email can be requested for
+// the same account.
+const passwordResetCooldown = time.Minute
+
+var errWrongPassword = errors.New("current password is incorrect")
+
+// ForgotPassword emails a single-use reset token. Like ResendVerification it
+// responds the same way for unknown emails.
+func (as *AuthService) ForgotPassword(ctx context.Context, body *ForgotPasswordReqBody) (*StatusResponse, *ErrorResponse) {
+	response := NewStatusResponse("if the account exists, a password reset email has been sent")
+
+	email := normalizeEmail(body.Email)
+	if email == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("email is required"))
+	}
+
+	user, err := as.repo.GetUserByEmail(ctx, email)
+	if err != nil || user.SuspendedAt != nil {
+		return response, nil
+	}
+
+	last, err := as.repo.LastPasswordResetTokenAt(ctx, user.ID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	if last != nil && time.Since(*last) < passwordResetCooldown {
+		return response, nil
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	err = as.repo.CreatePasswordResetToken(ctx, user.ID, hashToken(token), time.Now().Add(as.cfg.PasswordResetTokenTTL))
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	err = as.mailer.Send(ctx, &mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body:    tokenEmailBody("reset your Go-Ride password", as.cfg.PasswordResetURL, token),
+	})
+	if err != nil {
+		as.logger.Error("failed to send password reset email",
+			slog.Int("user_id", user.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, NewErrorResponse(fmt.Errorf("failed to send password reset email"))
+	}
+
+	as.logger.Info("password reset requested",
+		slog.Int("user_id", user.ID),
+	)
+
+	return response, nil
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere.
+func (as *AuthService) ResetPassword(ctx context.Context, body *ResetPasswordReqBody) (*StatusResponse, *ErrorResponse) {
+	token := strings.TrimSpace(body.Token)
+	if token == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidToken)
+	}
+
+	if msg := checkPasswordStrength(body.NewPassword); msg != "" {
+		return nil, NewValidationErrorResponse([]FieldError{{Field: "new_password", Message: msg}})
+	}
+
+	passwordHash, err := getPasswordHash(body.NewPassword)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	userID, err := as.repo.ResetPassword(ctx, hashToken(token), passwordHash)
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	as.versions.forget(userID)
+
+	as.logger.Info("password reset",
+		slog.Int("user_id", userID),
+	)
+
+	return NewStatusResponse("password changed"), nil
+}
+
+// ChangePassword replaces the password of a signed-in user. All refresh
+// tokens are revoked, so other devices have to log in again.
+func (as *AuthService) ChangePassword(ctx context.Context, userID int, body *ChangePasswordReqBody) (*StatusResponse, *ErrorResponse) {
+	currentHash, err := as.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+
+	if !checkPassword(currentHash, body.CurrentPassword) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errWrongPassword)
+	}
+
+	if msg := checkPasswordStrength(body.NewPassword); msg != "" {
+		return nil, NewValidationErrorResponse([]FieldError{{Field: "new_password", Message: msg}})
+	}
+
+	if body.NewPassword == body.CurrentPassword {
+		return nil, NewValidationErrorResponse([]FieldError{{Field: "new_password", Message: "must differ from the current password"}})
+	}
+
+	passwordHash, err := getPasswordHash(body.NewPassword)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if err := as.repo.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return nil, userErrorResponse(err)
+	}
+	as.versions.forget(userID)
+
+	as.logger.Info("password changed",
+		slog.Int("user_id", userID),
+	)
+
+	return NewStatusResponse("password changed"), nil
+}
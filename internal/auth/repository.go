@@ -3,11 +3,20 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	userSummaryColumns  = "id, name, email, role, suspended_at, email_verified_at, phone, phone_verified_at, token_version, deleted_at, created_at"
+	userColumns         = "id, name, email, password_hash, role, suspended_at, email_verified_at, phone, phone_verified_at, token_version"
+	uniqueViolationCode = "23505"
 )
 
 type postgresRepo struct {
@@ -21,8 +30,15 @@ func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
 
 func (pr *postgresRepo) CreateUser(ctx context.Context, user *User, passwordHash string) (int, error) {
 	var id int
-	err := pr.db.QueryRowContext(ctx, "INSERT INTO users (name, email, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id", user.Name, user.Email, passwordHash, user.Role).Scan(&id)
+	err := pr.db.QueryRowContext(ctx, "INSERT INTO users (name, email, password_hash, role, phone) VALUES ($1, $2, $3, $4, $5) RETURNING id", user.Name, user.Email, passwordHash, user.Role, user.Phone).Scan(&id)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			if pqErr.Constraint == "users_phone_key" {
+				return 0, errPhoneTaken
+			}
+			return 0, errEmailTaken
+		}
 		pr.logger.Error("failed to create user",
 			slog.String("user_email", user.Email),
 			slog.String("error", err.Error()),
@@ -32,76 +48,1322 @@ func (pr *postgresRepo) CreateUser(ctx context.Context, user *User, passwordHash
 	return id, nil
 }
 
-func (pr *postgresRepo) DeleteRefreshToken(ctx context.Context, refreshToken string) error {
-	_, err := pr.db.ExecContext(ctx, "DELETE from refresh_tokens WHERE token = $1", refreshToken)
+// DeleteRefreshToken ends the session the token belongs to, so tokens
+// consumed earlier in the same session can't be replayed. It returns the ID of
+// the ended session, or an empty string if the token was unknown.
+func (pr *postgresRepo) DeleteRefreshToken(ctx context.Context, tokenHash string) (string, error) {
+	var sessionID string
+
+	err := pr.db.GetContext(ctx, &sessionID, "DELETE FROM sessions WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1) RETURNING id", tokenHash)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
 		pr.logger.Error("failed to delete refresh_token",
-			slog.String("refresh_token", refreshToken),
 			slog.String("error", err.Error()),
 		)
-		return fmt.Errorf("failed to delete refresh token: %w", err)
+		return "", fmt.Errorf("failed to delete refresh token: %w", err)
+	}
+	return sessionID, nil
+}
+
+// CreateSession stores a new session together with its first refresh token.
+func (pr *postgresRepo) CreateSession(ctx context.Context, userID int, sessionID string, client *ClientInfo, mfa bool, tokenHash string, expiresAt time.Time) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to create session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var deviceName *string
+	if client.DeviceName != "" {
+		deviceName = &client.DeviceName
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO sessions (id, user_id, device_name, user_agent, ip, mfa) VALUES ($1, $2, $3, $4, $5, $6)", sessionID, userID, deviceName, client.UserAgent, client.IP, mfa)
+	if err != nil {
+		pr.logger.Error("failed to create session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, session_id, expires_at) VALUES ($1, $2, $3, $4)", userID, tokenHash, sessionID, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create new refresh token",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to create session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create session: %w", err)
 	}
+
 	return nil
 }
 
-func (pr *postgresRepo) GetUserIDByRefreshToken(ctx context.Context, refreshToken string) (int, error) {
-	var userID int
-	err := pr.db.QueryRowContext(ctx, "SELECT user_id FROM refresh_tokens WHERE token = $1", refreshToken).Scan(&userID)
+// RotateRefreshToken consumes the token with oldHash and stores newHash in the
+// same session. Presenting a token that was already consumed means it leaked,
+// so the whole session is revoked and errRefreshTokenReused is returned.
+func (pr *postgresRepo) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time, client *ClientInfo) (*sessionRef, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
 	if err != nil {
-		pr.logger.Error("failed to get user by refresh_token",
-			slog.String("refresh_token", refreshToken),
+		pr.logger.Error("failed to rotate refresh token",
 			slog.String("error", err.Error()),
 		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var current struct {
+		UserID    int        `db:"user_id"`
+		SessionID string     `db:"session_id"`
+		ExpiresAt time.Time  `db:"expires_at"`
+		UsedAt    *time.Time `db:"used_at"`
+		MFA       bool       `db:"mfa"`
+	}
+	err = tx.GetContext(ctx, &current, `SELECT rt.user_id, rt.session_id, rt.expires_at, rt.used_at, s.mfa
+		FROM refresh_tokens rt JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = $1 FOR UPDATE OF rt`, oldHash)
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("refresh token not found")
+			return nil, errInvalidRefreshToken
 		}
-		return 0, err
+		pr.logger.Error("failed to get refresh token",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
 	}
-	return userID, nil
+
+	if current.UsedAt != nil {
+		_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", current.SessionID)
+		if err != nil {
+			pr.logger.Error("failed to revoke session",
+				slog.Int("user_id", current.UserID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			pr.logger.Error("failed to revoke session",
+				slog.Int("user_id", current.UserID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+
+		return &sessionRef{UserID: current.UserID, SessionID: current.SessionID}, errRefreshTokenReused
+	}
+
+	if !current.ExpiresAt.After(time.Now()) {
+		err = errInvalidRefreshToken
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1", oldHash)
+	if err != nil {
+		pr.logger.Error("failed to consume refresh token",
+			slog.Int("user_id", current.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, session_id, expires_at) VALUES ($1, $2, $3, $4)", current.UserID, newHash, current.SessionID, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create new refresh token",
+			slog.Int("user_id", current.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE sessions SET user_agent = $1, ip = $2, last_used_at = now() WHERE id = $3", client.UserAgent, client.IP, current.SessionID)
+	if err != nil {
+		pr.logger.Error("failed to update session",
+			slog.Int("user_id", current.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to rotate refresh token",
+			slog.Int("user_id", current.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return &sessionRef{UserID: current.UserID, SessionID: current.SessionID, MFA: current.MFA}, nil
+}
+
+// DeleteExpiredRefreshTokens purges expired tokens and the sessions left
+// without any. Consumed tokens are kept until they expire so that replays can
+// still be detected.
+func (pr *postgresRepo) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at <= now()")
+	if err != nil {
+		pr.logger.Error("failed to delete expired refresh tokens",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+
+	_, err = pr.db.ExecContext(ctx, "DELETE FROM sessions s WHERE NOT EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.session_id = s.id)")
+	if err != nil {
+		pr.logger.Error("failed to delete expired sessions",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	return deleted, nil
 }
 
-func (pr *postgresRepo) GetUserRoleByID(ctx context.Context, userID int) (string, error) {
-	var role string
-	err := pr.db.QueryRowContext(ctx, "SELECT role FROM users WHERE id = $1", userID).Scan(&role)
+// ListSessions returns the user's sessions that still hold a usable refresh
+// token, most recently used first.
+func (pr *postgresRepo) ListSessions(ctx context.Context, userID int) ([]Session, error) {
+	sessions := []Session{}
+
+	err := pr.db.SelectContext(ctx, &sessions, `SELECT id, device_name, user_agent, ip, created_at, last_used_at FROM sessions s
+		WHERE user_id = $1 AND EXISTS (SELECT 1 FROM refresh_tokens rt WHERE rt.session_id = s.id AND rt.used_at IS NULL AND rt.expires_at > now())
+		ORDER BY last_used_at DESC`, userID)
 	if err != nil {
-		pr.logger.Error("failed to get user role by ID",
+		pr.logger.Error("failed to list sessions",
 			slog.Int("user_id", userID),
 			slog.String("error", err.Error()),
 		)
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("user with this id not found")
-		}
-		return "", err
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (pr *postgresRepo) DeleteSession(ctx context.Context, userID int, sessionID string) error {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1 AND user_id = $2", sessionID, userID)
+	if err != nil {
+		pr.logger.Error("failed to delete session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	if deleted, err := res.RowsAffected(); err == nil && deleted == 0 {
+		return errSessionNotFound
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) SessionExists(ctx context.Context, userID int, sessionID string) (bool, error) {
+	var exists bool
+
+	err := pr.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2)", sessionID, userID)
+	if err != nil {
+		pr.logger.Error("failed to check session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return exists, nil
+}
+
+// DeleteSessions ends every session of the user and bumps the token version,
+// so access tokens already issued stop working too.
+func (pr *postgresRepo) DeleteSessions(ctx context.Context, userID int) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "WITH bumped AS (UPDATE users SET token_version = token_version + 1 WHERE id = $1) DELETE FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to delete sessions",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
 	}
-	return role, nil
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
 }
 
 func (pr *postgresRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	user := &User{}
 
-	err := pr.db.QueryRowContext(ctx, "SELECT id, name, password_hash, role FROM users WHERE email = $1", email).Scan(&user.ID, &user.Name, &user.Password, &user.Role)
+	err := pr.db.GetContext(ctx, user, "SELECT "+userColumns+" FROM users WHERE lower(email) = $1", email)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
 		pr.logger.Error("failed to get user by email",
-			slog.String("user_email", user.Email),
+			slog.String("user_email", email),
 			slog.String("error", err.Error()),
 		)
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (pr *postgresRepo) GetUserByPhone(ctx context.Context, phone string) (*User, error) {
+	user := &User{}
+
+	err := pr.db.GetContext(ctx, user, "SELECT "+userColumns+" FROM users WHERE phone = $1", phone)
+	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
-		return nil, err
+		pr.logger.Error("failed to get user by phone",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	return user, nil
 }
 
-func (pr *postgresRepo) SaveRefreshToken(ctx context.Context, userID int, refreshToken string, refreshTokenExpire time.Time) error {
-	_, err := pr.db.ExecContext(ctx, "INSERT INTO refresh_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)", userID, refreshToken, refreshTokenExpire)
+func (pr *postgresRepo) MarkPhoneVerified(ctx context.Context, userID int) error {
+	_, err := pr.db.ExecContext(ctx, "UPDATE users SET phone_verified_at = now() WHERE id = $1 AND phone_verified_at IS NULL", userID)
 	if err != nil {
-		pr.logger.Error("failed to create new refresh token",
-			slog.Int("user_i", userID),
+		pr.logger.Error("failed to mark phone verified",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to mark phone verified: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) GetUserByID(ctx context.Context, userID int) (*UserSummary, error) {
+	var user UserSummary
+
+	err := pr.db.GetContext(ctx, &user, "SELECT "+userSummaryColumns+" FROM users WHERE id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		pr.logger.Error("failed to get user by ID",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (pr *postgresRepo) ListUsers(ctx context.Context, filter *UsersFilter) ([]UserSummary, error) {
+	users := []UserSummary{}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.Query != "" {
+		addCondition("(name ILIKE ? OR email ILIKE ? OR phone ILIKE ?)", "%"+filter.Query+"%")
+	}
+	if filter.Role != "" {
+		addCondition("role = ?", filter.Role)
+	}
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			conditions = append(conditions, "suspended_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "suspended_at IS NULL")
+		}
+	}
+
+	query := "SELECT " + userSummaryColumns + " FROM users"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	err := pr.db.SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		pr.logger.Error("failed to list users",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetSuspended suspends or reinstates the user. Suspending also ends the
+// user's sessions and bumps the token version, so every token is revoked.
+func (pr *postgresRepo) SetSuspended(ctx context.Context, userID int, suspended bool) (*UserSummary, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update user suspension",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var user UserSummary
+	err = tx.GetContext(ctx, &user, `UPDATE users SET suspended_at = CASE WHEN $1 THEN COALESCE(suspended_at, now()) END,
+		token_version = CASE WHEN $1 THEN token_version + 1 ELSE token_version END
+		WHERE id = $2 RETURNING `+userSummaryColumns, suspended, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		pr.logger.Error("failed to update user suspension",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if suspended {
+		_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+		if err != nil {
+			pr.logger.Error("failed to delete sessions",
+				slog.Int("user_id", userID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update user suspension",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (pr *postgresRepo) CreateVerificationToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO email_verification_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)", userID, tokenHash, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create verification token",
+			slog.Int("user_id", userID),
 			slog.String("error", err.Error()),
 		)
-		return fmt.Errorf("failed to create new refresh token: %w", err)
+		return fmt.Errorf("failed to create verification token: %w", err)
 	}
 	return nil
 }
+
+func (pr *postgresRepo) LastVerificationTokenAt(ctx context.Context, userID int) (*time.Time, error) {
+	var createdAt *time.Time
+
+	err := pr.db.GetContext(ctx, &createdAt, "SELECT max(created_at) FROM email_verification_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to get last verification token",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get last verification token: %w", err)
+	}
+
+	return createdAt, nil
+}
+
+// VerifyEmail consumes the token and marks the owner's email as verified.
+// Other outstanding tokens of the user are consumed too.
+func (pr *postgresRepo) VerifyEmail(ctx context.Context, tokenHash string) (int, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to verify email",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var userID int
+	err = tx.GetContext(ctx, &userID, "SELECT user_id FROM email_verification_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() FOR UPDATE", tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errInvalidToken
+		}
+		pr.logger.Error("failed to get verification token",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE email_verification_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		pr.logger.Error("failed to consume verification tokens",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to mark email as verified",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to verify email",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (pr *postgresRepo) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	var passwordHash string
+
+	err := pr.db.GetContext(ctx, &passwordHash, "SELECT password_hash FROM users WHERE id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrUserNotFound
+		}
+		pr.logger.Error("failed to get password hash",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return passwordHash, nil
+}
+
+// UpdatePassword sets the password hash, ends all of the user's sessions and
+// bumps the token version.
+func (pr *postgresRepo) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update password",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = pr.setPassword(ctx, tx, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update password",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)", userID, tokenHash, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create password reset token",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) LastPasswordResetTokenAt(ctx context.Context, userID int) (*time.Time, error) {
+	var createdAt *time.Time
+
+	err := pr.db.GetContext(ctx, &createdAt, "SELECT max(created_at) FROM password_reset_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to get last password reset token",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get last password reset token: %w", err)
+	}
+
+	return createdAt, nil
+}
+
+// ResetPassword consumes the token, along with every other outstanding reset
+// token of the user, and sets the new password.
+func (pr *postgresRepo) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to reset password",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var userID int
+	err = tx.GetContext(ctx, &userID, "SELECT user_id FROM password_reset_tokens WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() FOR UPDATE", tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errInvalidToken
+		}
+		pr.logger.Error("failed to get password reset token",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE password_reset_tokens SET used_at = now() WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		pr.logger.Error("failed to consume password reset tokens",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	err = pr.setPassword(ctx, tx, userID, passwordHash)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to reset password",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (pr *postgresRepo) setPassword(ctx context.Context, tx *sqlx.Tx, userID int, passwordHash string) error {
+	res, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = $1, token_version = token_version + 1 WHERE id = $2", passwordHash, userID)
+	if err != nil {
+		pr.logger.Error("failed to update password",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if updated, err := res.RowsAffected(); err == nil && updated == 0 {
+		return ErrUserNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to revoke sessions",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) GetTokenVersion(ctx context.Context, userID int) (int, error) {
+	var version int
+
+	err := pr.db.GetContext(ctx, &version, "SELECT token_version FROM users WHERE id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		pr.logger.Error("failed to get token version",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to get token version: %w", err)
+	}
+
+	return version, nil
+}
+
+// GetLoginLock returns the latest lockout among keys that hasn't expired yet.
+func (pr *postgresRepo) GetLoginLock(ctx context.Context, keys []string) (*time.Time, error) {
+	var lockedUntil *time.Time
+
+	err := pr.db.GetContext(ctx, &lockedUntil, "SELECT max(locked_until) FROM login_attempts WHERE key = ANY($1) AND locked_until > now()", pq.Array(keys))
+	if err != nil {
+		pr.logger.Error("failed to get login lock",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get login lock: %w", err)
+	}
+
+	return lockedUntil, nil
+}
+
+// RecordLoginFailure counts a failed attempt and returns the number of
+// failures in a row. The count starts over once window has passed since the
+// previous failure.
+func (pr *postgresRepo) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+
+	err := pr.db.GetContext(ctx, &failures, `INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < now() - make_interval(secs => $2) THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = now()
+		RETURNING failures`, key, window.Seconds())
+	if err != nil {
+		pr.logger.Error("failed to record login failure",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+func (pr *postgresRepo) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := pr.db.ExecContext(ctx, "UPDATE login_attempts SET locked_until = $1 WHERE key = $2", until, key)
+	if err != nil {
+		pr.logger.Error("failed to lock login",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := pr.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	if err != nil {
+		pr.logger.Error("failed to reset login attempts",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
+
+// DeleteStaleLoginAttempts removes counters that are no longer locked and
+// have had no failures within window.
+func (pr *postgresRepo) DeleteStaleLoginAttempts(ctx context.Context, window time.Duration) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE last_failure_at < now() - make_interval(secs => $1) AND (locked_until IS NULL OR locked_until <= now())", window.Seconds())
+	if err != nil {
+		pr.logger.Error("failed to delete stale login attempts",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete stale login attempts: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
+}
+
+func (pr *postgresRepo) GetMFA(ctx context.Context, userID int) (*userMFA, error) {
+	var mfa userMFA
+
+	err := pr.db.GetContext(ctx, &mfa, "SELECT secret, enabled_at, last_counter FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errMFANotEnrolled
+		}
+		pr.logger.Error("failed to get two-factor settings",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+
+	return &mfa, nil
+}
+
+// SaveMFASecret starts or restarts enrollment. It fails with
+// errMFAAlreadyEnabled once two-factor authentication is on.
+func (pr *postgresRepo) SaveMFASecret(ctx context.Context, userID int, secret string) error {
+	res, err := pr.db.ExecContext(ctx, `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_counter = 0, created_at = now()
+		WHERE user_mfa.enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		pr.logger.Error("failed to save two-factor secret",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+
+	if saved, err := res.RowsAffected(); err == nil && saved == 0 {
+		return errMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableMFA turns two-factor authentication on, records the step of the code
+// it was confirmed with and stores the recovery codes.
+func (pr *postgresRepo) EnableMFA(ctx context.Context, userID int, counter int64, codeHashes []string) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to enable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE user_mfa SET enabled_at = now(), last_counter = $1 WHERE user_id = $2 AND enabled_at IS NULL", counter, userID)
+	if err != nil {
+		pr.logger.Error("failed to enable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	if updated, rowsErr := res.RowsAffected(); rowsErr == nil && updated == 0 {
+		err = errMFAAlreadyEnabled
+		return err
+	}
+
+	err = pr.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to enable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) DisableMFA(ctx context.Context, userID int) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to disable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to delete recovery codes",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to disable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to disable two-factor authentication",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records that the code of the given step was used. It
+// returns false if that step or a later one was used already.
+func (pr *postgresRepo) UseTOTPCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	res, err := pr.db.ExecContext(ctx, "UPDATE user_mfa SET last_counter = $1 WHERE user_id = $2 AND last_counter < $1", counter, userID)
+	if err != nil {
+		pr.logger.Error("failed to use two-factor code",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to use two-factor code: %w", err)
+	}
+
+	updated, _ := res.RowsAffected()
+	return updated == 1, nil
+}
+
+func (pr *postgresRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	res, err := pr.db.ExecContext(ctx, "UPDATE mfa_recovery_codes SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", userID, codeHash)
+	if err != nil {
+		pr.logger.Error("failed to use recovery code",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	updated, _ := res.RowsAffected()
+	return updated == 1, nil
+}
+
+func (pr *postgresRepo) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to replace recovery codes",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = pr.replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to replace recovery codes",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID int, codeHashes []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		pr.logger.Error("failed to delete recovery codes",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::TEXT[])", userID, pq.Array(codeHashes))
+	if err != nil {
+		pr.logger.Error("failed to create recovery codes",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func (pr *postgresRepo) MarkSessionMFA(ctx context.Context, userID int, sessionID string) error {
+	_, err := pr.db.ExecContext(ctx, "UPDATE sessions SET mfa = true WHERE id = $1 AND user_id = $2", sessionID, userID)
+	if err != nil {
+		pr.logger.Error("failed to update session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) CreateMFAChallenge(ctx context.Context, userID int, tokenHash string, deviceName *string, expiresAt time.Time) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO mfa_challenges (token_hash, user_id, device_name, expires_at) VALUES ($1, $2, $3, $4)", tokenHash, userID, deviceName, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create two-factor challenge",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create two-factor challenge: %w", err)
+	}
+	return nil
+}
+
+// UseMFAChallengeAttempt counts an attempt to answer the challenge. It fails
+// with errInvalidToken once the challenge has expired or run out of attempts.
+func (pr *postgresRepo) UseMFAChallengeAttempt(ctx context.Context, tokenHash string, maxAttempts int) (*mfaChallenge, error) {
+	var challenge mfaChallenge
+
+	err := pr.db.GetContext(ctx, &challenge, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token_hash = $1 AND expires_at > now() AND attempts < $2 RETURNING user_id, device_name", tokenHash, maxAttempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidToken
+		}
+		pr.logger.Error("failed to get two-factor challenge",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get two-factor challenge: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+func (pr *postgresRepo) DeleteMFAChallenge(ctx context.Context, tokenHash string) error {
+	_, err := pr.db.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		pr.logger.Error("failed to delete two-factor challenge",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to delete two-factor challenge: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) DeleteExpiredMFAChallenges(ctx context.Context) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM mfa_challenges WHERE expires_at <= now()")
+	if err != nil {
+		pr.logger.Error("failed to delete expired two-factor challenges",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete expired two-factor challenges: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
+}
+
+func (pr *postgresRepo) GetMFARequiredRoles(ctx context.Context) ([]string, error) {
+	roles := []string{}
+
+	err := pr.db.SelectContext(ctx, &roles, "SELECT role FROM mfa_required_roles ORDER BY role")
+	if err != nil {
+		pr.logger.Error("failed to get two-factor policy",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get two-factor policy: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (pr *postgresRepo) SetMFARequiredRoles(ctx context.Context, roles []string) error {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update two-factor policy",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update two-factor policy: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfa_required_roles")
+	if err != nil {
+		pr.logger.Error("failed to update two-factor policy",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update two-factor policy: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO mfa_required_roles (role) SELECT unnest($1::TEXT[])", pq.Array(roles))
+	if err != nil {
+		pr.logger.Error("failed to update two-factor policy",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update two-factor policy: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update two-factor policy",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update two-factor policy: %w", err)
+	}
+
+	return nil
+}
+
+// CreateOTPCode replaces the login code for phone. It fails with
+// errTooManyOTPRequests if the previous code was sent less than
+// resendInterval ago or maxSends codes were already sent within window.
+func (pr *postgresRepo) CreateOTPCode(ctx context.Context, phone, codeHash string, expiresAt time.Time, resendInterval, window time.Duration, maxSends int) error {
+	var sent string
+
+	err := pr.db.GetContext(ctx, &sent, `INSERT INTO otp_codes (phone, code_hash, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (phone) DO UPDATE SET
+			code_hash = EXCLUDED.code_hash,
+			expires_at = EXCLUDED.expires_at,
+			attempts = 0,
+			sent_at = now(),
+			window_started_at = CASE WHEN otp_codes.window_started_at < now() - make_interval(secs => $5) THEN now() ELSE otp_codes.window_started_at END,
+			sends = CASE WHEN otp_codes.window_started_at < now() - make_interval(secs => $5) THEN 1 ELSE otp_codes.sends + 1 END
+		WHERE otp_codes.sent_at <= now() - make_interval(secs => $4)
+			AND (otp_codes.window_started_at < now() - make_interval(secs => $5) OR otp_codes.sends < $6)
+		RETURNING phone`, phone, codeHash, expiresAt, resendInterval.Seconds(), window.Seconds(), maxSends)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errTooManyOTPRequests
+		}
+		pr.logger.Error("failed to create login code",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create login code: %w", err)
+	}
+
+	return nil
+}
+
+// UseOTPCode counts an attempt to enter the login code for phone and reports
+// whether codeHash matched. A matched code expires at once, so it can't be
+// used twice. It fails with errInvalidToken once the code has expired or run
+// out of attempts.
+func (pr *postgresRepo) UseOTPCode(ctx context.Context, phone, codeHash string, maxAttempts int) (bool, error) {
+	var matched bool
+
+	err := pr.db.GetContext(ctx, &matched, `UPDATE otp_codes SET
+			attempts = attempts + 1,
+			expires_at = CASE WHEN code_hash = $2 THEN now() ELSE expires_at END
+		WHERE phone = $1 AND expires_at > now() AND attempts < $3
+		RETURNING code_hash = $2`, phone, codeHash, maxAttempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, errInvalidToken
+		}
+		pr.logger.Error("failed to use login code",
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to use login code: %w", err)
+	}
+
+	return matched, nil
+}
+
+// DeleteStaleOTPCodes removes expired codes whose rate limits no longer
+// matter, i.e. the last one was sent more than olderThan ago.
+func (pr *postgresRepo) DeleteStaleOTPCodes(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM otp_codes WHERE expires_at <= now() AND sent_at < now() - make_interval(secs => $1)", olderThan.Seconds())
+	if err != nil {
+		pr.logger.Error("failed to delete stale login codes",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete stale login codes: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
+}
+
+func (pr *postgresRepo) CreateOIDCState(ctx context.Context, stateHash string, state *oidcState, expiresAt time.Time) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, device_name, expires_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		stateHash, state.Provider, state.Nonce, state.CodeVerifier, state.DeviceName, expiresAt)
+	if err != nil {
+		pr.logger.Error("failed to create oidc state",
+			slog.String("provider", state.Provider),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to create oidc state: %w", err)
+	}
+	return nil
+}
+
+// TakeOIDCState deletes and returns the state of a login started with
+// provider, so a callback can't be replayed. It fails with errInvalidToken
+// if there is no such unexpired state.
+func (pr *postgresRepo) TakeOIDCState(ctx context.Context, stateHash, provider string) (*oidcState, error) {
+	var state oidcState
+
+	err := pr.db.GetContext(ctx, &state, "DELETE FROM oidc_states WHERE state_hash = $1 AND provider = $2 AND expires_at > now() RETURNING provider, nonce, code_verifier, device_name", stateHash, provider)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errInvalidToken
+		}
+		pr.logger.Error("failed to get oidc state",
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get oidc state: %w", err)
+	}
+
+	return &state, nil
+}
+
+func (pr *postgresRepo) DeleteExpiredOIDCStates(ctx context.Context) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM oidc_states WHERE expires_at <= now()")
+	if err != nil {
+		pr.logger.Error("failed to delete expired oidc states",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete expired oidc states: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
+}
+
+func (pr *postgresRepo) GetUserByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	user := &User{}
+
+	err := pr.db.GetContext(ctx, user, "SELECT "+userColumns+" FROM users WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)", provider, subject)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		pr.logger.Error("failed to get user by identity",
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity attaches a provider account to the user. It fails with
+// errIdentityLinked if the user already has another account of provider
+// linked.
+func (pr *postgresRepo) LinkIdentity(ctx context.Context, userID int, provider, subject, email string) error {
+	_, err := pr.db.ExecContext(ctx, "INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, NULLIF($4, ''))", provider, subject, userID, email)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			return errIdentityLinked
+		}
+		pr.logger.Error("failed to link identity",
+			slog.Int("user_id", userID),
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// CreateOIDCUser creates a user together with the provider account it signed
+// up with. The email counts as verified when the provider said so.
+func (pr *postgresRepo) CreateOIDCUser(ctx context.Context, user *User, passwordHash string, emailVerified bool, provider, subject string) (int, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to create user",
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `INSERT INTO users (name, email, password_hash, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN now() END) RETURNING id`, user.Name, user.Email, passwordHash, user.Role, emailVerified).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			return 0, errEmailTaken
+		}
+		pr.logger.Error("failed to create user",
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)", provider, subject, id, user.Email)
+	if err != nil {
+		pr.logger.Error("failed to link identity",
+			slog.Int("user_id", id),
+			slog.String("provider", provider),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to create user",
+			slog.Int("user_id", id),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return id, nil
+}
@@ -3,44 +3,127 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/AzizovHikmatullo/go-ride/internal/mailer"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/AzizovHikmatullo/go-ride/internal/sms"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUserNotFound     = errors.New("user with this id not found")
+	errAccountSuspended = errors.New("account is suspended")
+	errSuspendSelf      = errors.New("you can't suspend your own account")
+	errEmailTaken       = errors.New("user with this email already exists")
+	errPhoneTaken       = errors.New("user with this phone number already exists")
+	errEmailNotVerified = errors.New("email is not verified")
+	errInvalidToken     = errors.New("token is invalid or has expired")
+
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token was already used, please log in again")
+	errSessionNotFound     = errors.New("session not found")
+)
+
 type RepositoryInterface interface {
 	CreateUser(ctx context.Context, user *User, passwordHash string) (int, error)
-	DeleteRefreshToken(ctx context.Context, refreshToken string) error
-	GetUserIDByRefreshToken(ctx context.Context, refreshToken string) (int, error)
-	GetUserRoleByID(ctx context.Context, userID int) (string, error)
+	DeleteRefreshToken(ctx context.Context, tokenHash string) (string, error)
+	CreateSession(ctx context.Context, userID int, sessionID string, client *ClientInfo, mfa bool, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time, client *ClientInfo) (*sessionRef, error)
+	DeleteExpiredRefreshTokens(ctx context.Context) (int64, error)
+	ListSessions(ctx context.Context, userID int) ([]Session, error)
+	DeleteSession(ctx context.Context, userID int, sessionID string) error
+	SessionExists(ctx context.Context, userID int, sessionID string) (bool, error)
+	DeleteSessions(ctx context.Context, userID int) (int64, error)
+	GetTokenVersion(ctx context.Context, userID int) (int, error)
+	GetLoginLock(ctx context.Context, keys []string) (*time.Time, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	DeleteStaleLoginAttempts(ctx context.Context, window time.Duration) (int64, error)
+	GetMFA(ctx context.Context, userID int) (*userMFA, error)
+	SaveMFASecret(ctx context.Context, userID int, secret string) error
+	EnableMFA(ctx context.Context, userID int, counter int64, codeHashes []string) error
+	DisableMFA(ctx context.Context, userID int) error
+	UseTOTPCounter(ctx context.Context, userID int, counter int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	MarkSessionMFA(ctx context.Context, userID int, sessionID string) error
+	CreateMFAChallenge(ctx context.Context, userID int, tokenHash string, deviceName *string, expiresAt time.Time) error
+	UseMFAChallengeAttempt(ctx context.Context, tokenHash string, maxAttempts int) (*mfaChallenge, error)
+	DeleteMFAChallenge(ctx context.Context, tokenHash string) error
+	DeleteExpiredMFAChallenges(ctx context.Context) (int64, error)
+	GetMFARequiredRoles(ctx context.Context) ([]string, error)
+	SetMFARequiredRoles(ctx context.Context, roles []string) error
+	GetUserByID(ctx context.Context, userID int) (*UserSummary, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
-	SaveRefreshToken(ctx context.Context, userID int, refreshToken string, refreshTokenExpire time.Time) error
+	GetUserByPhone(ctx context.Context, phone string) (*User, error)
+	MarkPhoneVerified(ctx context.Context, userID int) error
+	CreateOTPCode(ctx context.Context, phone, codeHash string, expiresAt time.Time, resendInterval, window time.Duration, maxSends int) error
+	UseOTPCode(ctx context.Context, phone, codeHash string, maxAttempts int) (bool, error)
+	DeleteStaleOTPCodes(ctx context.Context, olderThan time.Duration) (int64, error)
+	CreateOIDCState(ctx context.Context, stateHash string, state *oidcState, expiresAt time.Time) error
+	TakeOIDCState(ctx context.Context, stateHash, provider string) (*oidcState, error)
+	DeleteExpiredOIDCStates(ctx context.Context) (int64, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*User, error)
+	LinkIdentity(ctx context.Context, userID int, provider, subject, email string) error
+	CreateOIDCUser(ctx context.Context, user *User, passwordHash string, emailVerified bool, provider, subject string) (int, error)
+	ListUsers(ctx context.Context, filter *UsersFilter) ([]UserSummary, error)
+	SetSuspended(ctx context.Context, userID int, suspended bool) (*UserSummary, error)
+	CreateVerificationToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	LastVerificationTokenAt(ctx context.Context, userID int) (*time.Time, error)
+	VerifyEmail(ctx context.Context, tokenHash string) (int, error)
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	LastPasswordResetTokenAt(ctx context.Context, userID int) (*time.Time, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error)
 }
 
 type AuthService struct {
-	repo            RepositoryInterface
-	jwtSecret       string
-	AccessTokenTTL  time.Duration
-	RefreshTokenTTL time.Duration
-	logger          *slog.Logger
+	repo     RepositoryInterface
+	mailer   mailer.Mailer
+	sms      sms.SMSSender
+	audit    AuditRecorder
+	cfg      Config
+	versions *versionCache
+	sessions *sessionCache
+	policy   *mfaPolicyCache
+	logger   *slog.Logger
 }
 
-func NewAuthService(repository RepositoryInterface, jwtSecret string, accessTokenTTL, refreshTokenTTL time.Duration, logger *slog.Logger) AuthServiceInterface {
+func NewAuthService(repository RepositoryInterface, mailer mailer.Mailer, smsSender sms.SMSSender, audit AuditRecorder, cfg Config, logger *slog.Logger) *AuthService {
 	return &AuthService{
-		repo:            repository,
-		jwtSecret:       jwtSecret,
-		AccessTokenTTL:  accessTokenTTL,
-		RefreshTokenTTL: refreshTokenTTL,
-		logger:          logger,
+		repo:     repository,
+		mailer:   mailer,
+		sms:      smsSender,
+		audit:    audit,
+		cfg:      cfg,
+		versions: newVersionCache(cfg.TokenVersionCacheTTL),
+		sessions: newSessionCache(cfg.TokenVersionCacheTTL),
+		policy:   &mfaPolicyCache{ttl: cfg.TokenVersionCacheTTL},
+		logger:   logger,
 	}
 }
 
 func (as *AuthService) CreateUser(ctx context.Context, body *RegisterReqBody) (*IDResponse, *ErrorResponse) {
-	user := &User{Name: body.Name, Email: body.Email, Password: body.Password, Role: body.Role}
+	body.Normalize()
+	if errs := body.Validate(); len(errs) > 0 {
+		return nil, NewValidationErrorResponse(errs)
+	}
+
+	user := &User{Name: body.Name, Email: body.Email, Password: body.Password, Role: rbac.RoleUser}
+	if body.Phone != "" {
+		user.Phone = &body.Phone
+	}
 
 	passwordHash, err := getPasswordHash(user.Password)
 	if err != nil {
@@ -49,6 +132,9 @@ func (as *AuthService) CreateUser(ctx context.Context, body *RegisterReqBody) (*
 
 	id, err := as.repo.CreateUser(ctx, user, passwordHash)
 	if err != nil {
+		if errors.Is(err, errEmailTaken) || errors.Is(err, errPhoneTaken) {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, err)
+		}
 		return nil, NewErrorResponse(err)
 	}
 
@@ -56,114 +142,313 @@ func (as *AuthService) CreateUser(ctx context.Context, body *RegisterReqBody) (*
 		slog.Int("user_id", id),
 	)
 
+	as.sendVerification(ctx, id, user.Email)
+
 	return &IDResponse{id}, nil
 }
 
 func (as *AuthService) LogoutUser(ctx context.Context, refreshToken string) (*StatusResponse, *ErrorResponse) {
-	err := as.repo.DeleteRefreshToken(ctx, refreshToken)
+	sessionID, err := as.repo.DeleteRefreshToken(ctx, hashToken(refreshToken))
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
+	as.sessions.forget(sessionID)
 
 	as.logger.Info("user loged out")
 
 	return NewStatusResponse("loged out"), nil
 }
 
-func (as *AuthService) LoginUser(ctx context.Context, body *LoginReqBody) (*TokenResponse, *ErrorResponse) {
-	user, err := as.repo.GetUserByEmail(ctx, body.Email)
+// LoginUser answers every wrong email or password with the same "invalid
+// credentials" error and locks out accounts and IPs that keep failing. For
+// accounts with two-factor authentication it returns a challenge instead of
+// tokens.
+func (as *AuthService) LoginUser(ctx context.Context, body *LoginReqBody, client *ClientInfo) (*LoginResponse, *ErrorResponse) {
+	email := normalizeEmail(body.Email)
+
+	if errResp := as.checkLoginLock(ctx, email, client.IP); errResp != nil {
+		return nil, errResp
+	}
+
+	user, err := as.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		return nil, NewErrorResponse(err)
+		if !errors.Is(err, ErrUserNotFound) {
+			return nil, NewErrorResponse(err)
+		}
+		checkDummyPassword(body.Password)
+		as.recordLoginFailure(ctx, email, client.IP, 0)
+		return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidCredentials)
 	}
 
 	if !checkPassword(user.Password, body.Password) {
-		return nil, NewErrorResponse(fmt.Errorf("invalid credentials"))
+		as.recordLoginFailure(ctx, email, client.IP, user.ID)
+		return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, errInvalidCredentials)
 	}
 
-	accessToken, _ := getAccessToken(user.ID, user.Role, as.jwtSecret, time.Now().Add(as.AccessTokenTTL))
-	refreshToken, _ := getRefreshToken()
+	if err := as.repo.ResetLoginAttempts(ctx, accountAttemptKey(email)); err != nil {
+		return nil, NewErrorResponse(err)
+	}
 
-	err = as.repo.SaveRefreshToken(ctx, user.ID, refreshToken, time.Now().Add(as.RefreshTokenTTL))
-	if err != nil {
+	return as.completeLogin(ctx, user, client, "password")
+}
+
+// completeLogin finishes a login once the user has proven who they are with
+// method: it applies the account restrictions and either opens a session or,
+// with two-factor authentication enabled, starts a challenge.
+func (as *AuthService) completeLogin(ctx context.Context, user *User, client *ClientInfo, method string) (*LoginResponse, *ErrorResponse) {
+	if user.SuspendedAt != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusForbidden, errAccountSuspended)
+	}
+
+	if as.cfg.RequireVerifiedEmail == VerificationForLogin && user.EmailVerifiedAt == nil {
+		return nil, NewErrorResponseWithStatus(http.StatusForbidden, errEmailNotVerified)
+	}
+
+	mfa, err := as.repo.GetMFA(ctx, user.ID)
+	if err != nil && !errors.Is(err, errMFANotEnrolled) {
 		return nil, NewErrorResponse(err)
 	}
+	if mfa != nil && mfa.EnabledAt != nil {
+		return as.startMFAChallenge(ctx, user.ID, client)
+	}
+
+	tokens, errResp := as.openSession(ctx, user.ID, user.Role, user.TokenVersion, client, false)
+	if errResp != nil {
+		return nil, errResp
+	}
 
 	as.logger.Info("user loged in",
-		slog.String("user_email", body.Email),
+		slog.Int("user_id", user.ID),
+		slog.String("method", method),
 	)
 
-	return &TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	return &LoginResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
 }
 
-func (as *AuthService) GenerateTokens(ctx context.Context, userID int, oldRefreshToken string) (*TokenResponse, *ErrorResponse) {
-	userID, err := as.repo.GetUserIDByRefreshToken(ctx, oldRefreshToken)
+// openSession starts a new session and issues its first tokens. Every login
+// starts a new session; refreshes rotate tokens within it.
+func (as *AuthService) openSession(ctx context.Context, userID int, role string, tokenVersion int, client *ClientInfo, mfa bool) (*TokenResponse, *ErrorResponse) {
+	sessionID, err := newSessionID()
 	if err != nil {
-		return nil, NewErrorResponse(fmt.Errorf("invalid refresh token"))
+		return nil, NewErrorResponse(err)
+	}
+
+	accessToken, err := as.getAccessToken(userID, role, sessionID, tokenVersion, mfa)
+	if err != nil {
+		return nil, NewErrorResponse(err)
 	}
 
-	role, err := as.repo.GetUserRoleByID(ctx, userID)
+	refreshToken, err := getRefreshToken()
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
-	accessToken, err := getAccessToken(userID, role, as.jwtSecret, time.Now().Add(as.AccessTokenTTL))
+	err = as.repo.CreateSession(ctx, userID, sessionID, client, mfa, hashToken(refreshToken), time.Now().Add(as.cfg.RefreshTokenTTL))
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
+	return &TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// GenerateTokens exchanges a refresh token for a new pair. The old refresh
+// token is consumed, so it can be used only once.
+func (as *AuthService) GenerateTokens(ctx context.Context, oldRefreshToken string, client *ClientInfo) (*TokenResponse, *ErrorResponse) {
 	refreshToken, err := getRefreshToken()
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
-	err = as.repo.SaveRefreshToken(ctx, userID, refreshToken, time.Now().Add(as.RefreshTokenTTL))
+	session, err := as.repo.RotateRefreshToken(ctx, hashToken(oldRefreshToken), hashToken(refreshToken), time.Now().Add(as.cfg.RefreshTokenTTL), client)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			as.logger.Warn("refresh token reuse detected, session revoked",
+				slog.Int("user_id", session.UserID),
+				slog.String("session_id", session.SessionID),
+			)
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, err)
+		}
+		if errors.Is(err, errInvalidRefreshToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusUnauthorized, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	user, err := as.repo.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	if user.SuspendedAt != nil {
+		return nil, NewErrorResponseWithStatus(http.StatusForbidden, errAccountSuspended)
+	}
+
+	accessToken, err := as.getAccessToken(user.ID, user.Role, session.SessionID, user.TokenVersion, session.MFA)
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
 	as.logger.Info("generated new tokens",
-		slog.Int("user_id", userID),
+		slog.Int("user_id", user.ID),
 	)
 
 	return &TokenResponse{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
-func (as *AuthService) GetUserIDByRefreshToken(ctx context.Context, refreshToken string) (int, *ErrorResponse) {
-	userID, err := as.repo.GetUserIDByRefreshToken(ctx, refreshToken)
+// RunCleanup purges expired refresh tokens, two-factor challenges and stale
+// login attempts every interval until ctx is done.
+func (as *AuthService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := as.repo.DeleteExpiredRefreshTokens(ctx); err == nil && deleted > 0 {
+				as.logger.Info("expired refresh tokens purged",
+					slog.Int64("count", deleted),
+				)
+			}
+			_, _ = as.repo.DeleteStaleLoginAttempts(ctx, as.cfg.LoginThrottle.Window)
+			_, _ = as.repo.DeleteExpiredMFAChallenges(ctx)
+			_, _ = as.repo.DeleteStaleOTPCodes(ctx, max(as.cfg.OTP.SendWindow, as.cfg.OTP.ResendInterval))
+			_, _ = as.repo.DeleteExpiredOIDCStates(ctx)
+		}
+	}
+}
+
+// JWKS returns the public keys access tokens can be verified with.
+func (as *AuthService) JWKS() *JWKSet {
+	return as.cfg.SigningKeys.JWKS()
+}
+
+func (as *AuthService) ListUsers(ctx context.Context, filter *UsersFilter) (*UsersResponse, *ErrorResponse) {
+	filter.normalize()
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.Role = strings.ToUpper(filter.Role)
+
+	users, err := as.repo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &UsersResponse{Users: users}, nil
+}
+
+func (as *AuthService) GetUser(ctx context.Context, userID int) (*UserSummary, *ErrorResponse) {
+	user, err := as.repo.GetUserByID(ctx, userID)
 	if err != nil {
-		return 0, NewErrorResponse(err)
+		return nil, userErrorResponse(err)
 	}
-	return userID, nil
+	return user, nil
 }
 
+// SuspendUser blocks the user from logging in and revokes every token issued
+// so far.
+func (as *AuthService) SuspendUser(ctx context.Context, userID, adminID int) (*UserSummary, *ErrorResponse) {
+	if userID == adminID {
+		return nil, NewErrorResponseWithStatus(http.StatusConflict, errSuspendSelf)
+	}
+
+	user, err := as.repo.SetSuspended(ctx, userID, true)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+	as.versions.forget(userID)
+
+	as.logger.Info("user suspended",
+		slog.Int("user_id", userID),
+		slog.Int("admin_id", adminID),
+	)
+
+	return user, nil
+}
+
+func (as *AuthService) UnsuspendUser(ctx context.Context, userID, adminID int) (*UserSummary, *ErrorResponse) {
+	user, err := as.repo.SetSuspended(ctx, userID, false)
+	if err != nil {
+		return nil, userErrorResponse(err)
+	}
+
+	as.logger.Info("user unsuspended",
+		slog.Int("user_id", userID),
+		slog.Int("admin_id", adminID),
+	)
+
+	return user, nil
+}
+
+func userErrorResponse(err error) *ErrorResponse {
+	if errors.Is(err, ErrUserNotFound) {
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	}
+	return NewErrorResponse(err)
+}
+
+// Claims of an access token. TokenVersion must match the user's current
+// token version, which is bumped to revoke every token issued before. MFA is
+// set when the session was opened with a second factor.
 type Claims struct {
-	UserID int    `json:"userID"`
-	Role   string `json:"role"`
+	UserID       int    `json:"userID"`
+	Role         string `json:"role"`
+	SessionID    string `json:"sid,omitempty"`
+	TokenVersion int    `json:"ver"`
+	MFA          bool   `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func getAccessToken(userID int, role string, secret string, expireTime time.Time) (string, error) {
+// getAccessToken signs with the active asymmetric key when signing keys are
+// configured and falls back to HS256 with the shared secret otherwise.
+func (as *AuthService) getAccessToken(userID int, role, sessionID string, tokenVersion int, mfa bool) (string, error) {
+	tokenID, err := newSessionID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:       userID,
+		Role:         role,
+		SessionID:    sessionID,
+		TokenVersion: tokenVersion,
+		MFA:          mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expireTime),
+			ID:        tokenID,
+			Issuer:    as.cfg.Issuer,
+			Audience:  jwt.ClaimStrings{as.cfg.Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(as.cfg.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	signedToken, err := token.SignedString([]byte(secret))
-
+	var signedToken string
+	if as.cfg.SigningKeys != nil {
+		signedToken, err = as.cfg.SigningKeys.sign(claims)
+	} else {
+		signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(as.cfg.JWTSecret))
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return "", fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	return signedToken, nil
 }
 
 func getRefreshToken() (string, error) {
+	return newRandomToken()
+}
+
+// newSessionID is shorter than a token since it's only an identifier and
+// shows up in URLs.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newRandomToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
@@ -171,6 +456,13 @@ func getRefreshToken() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// hashToken is how refresh and single-use tokens are stored, so a leaked table can't be
+// used to take over accounts.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func getPasswordHash(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxDeviceNameLength = 100
+	maxUserAgentLength  = 512
+)
+
+// NewClientInfo trims what the client sent to sizes worth storing.
+func NewClientInfo(deviceName, userAgent, ip string) *ClientInfo {
+	return &ClientInfo{
+		DeviceName: truncate(strings.TrimSpace(deviceName), maxDeviceNameLength),
+		UserAgent:  truncate(userAgent, maxUserAgentLength),
+		IP:         ip,
+	}
+}
+
+// ListSessions returns the active sessions of the user. currentSessionID
+// marks the session the request was made from.
+func (as *AuthService) ListSessions(ctx context.Context, userID int, currentSessionID string) (*SessionsResponse, *ErrorResponse) {
+	sessions, err := as.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return &SessionsResponse{Sessions: sessions}, nil
+}
+
+// ListUserSessions is ListSessions for admins, who look up users by ID.
+func (as *AuthService) ListUserSessions(ctx context.Context, userID int) (*SessionsResponse, *ErrorResponse) {
+	if _, err := as.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, userErrorResponse(err)
+	}
+	return as.ListSessions(ctx, userID, "")
+}
+
+// RevokeSession ends one session together with the access tokens issued for
+// it.
+func (as *AuthService) RevokeSession(ctx context.Context, userID int, sessionID string) (*StatusResponse, *ErrorResponse) {
+	err := as.repo.DeleteSession(ctx, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			return nil, NewErrorResponseWithStatus(http.StatusNotFound, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	as.sessions.forget(sessionID)
+
+	as.logger.Info("session revoked",
+		slog.Int("user_id", userID),
+		slog.String("session_id", sessionID),
+	)
+
+	return NewStatusResponse("session revoked"), nil
+}
+
+// RevokeAllSessions ends every session of the user and revokes the access
+// tokens issued for them.
+func (as *AuthService) RevokeAllSessions(ctx context.Context, userID int) (*StatusResponse, *ErrorResponse) {
+	if _, err := as.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, userErrorResponse(err)
+	}
+
+	deleted, err := as.repo.DeleteSessions(ctx, userID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	as.versions.forget(userID)
+
+	as.logger.Info("all sessions revoked",
+		slog.Int("user_id", userID),
+		slog.Int64("count", deleted),
+	)
+
+	return NewStatusResponse("loged out from all sessions"), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Don't cut a multi-byte character in half.
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/audit"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+	errTooManyAttempts    = errors.New("too many login attempts, try again later")
+	errTooManyMFAAttempts = errors.New("too many two-factor attempts, try again later")
+)
+
+// LoginThrottle configures brute-force protection for LoginUser. Once a key
+// reaches its attempt limit within Window, every further failure locks it for
+// LockoutBase, doubling up to LockoutMax.
+type LoginThrottle struct {
+	MaxAttempts      int
+	MaxAttemptsPerIP int
+	Window           time.Duration
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+}
+
+// AuditRecorder writes security events to the audit log.
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *audit.Entry)
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// checkDummyPassword spends as long as a real password check, so the
+// response time doesn't tell whether an email is registered.
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("go-ride-dummy-password"), bcrypt.DefaultCost)
+		dummyHash = string(hash)
+	})
+	checkPassword(dummyHash, password)
+}
+
+func accountAttemptKey(email string) string {
+	return "email:" + email
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// mfaAttemptKey counts wrong second factors of a user across all challenges,
+// so knowing the password doesn't buy unlimited guesses.
+func mfaAttemptKey(userID int) string {
+	return "mfa:" + strconv.Itoa(userID)
+}
+
+// checkLoginLock returns errTooManyAttempts while the account or the IP is
+// locked out.
+func (as *AuthService) checkLoginLock(ctx context.Context, email, ip string) *ErrorResponse {
+	lockedUntil, err := as.repo.GetLoginLock(ctx, []string{accountAttemptKey(email), ipAttemptKey(ip)})
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+	if lockedUntil != nil {
+		return NewErrorResponseWithStatus(http.StatusTooManyRequests, errTooManyAttempts)
+	}
+	return nil
+}
+
+// checkMFALock returns errTooManyMFAAttempts while the user's second factor
+// is locked out.
+func (as *AuthService) checkMFALock(ctx context.Context, userID int) *ErrorResponse {
+	lockedUntil, err := as.repo.GetLoginLock(ctx, []string{mfaAttemptKey(userID)})
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+	if lockedUntil != nil {
+		return NewErrorResponseWithStatus(http.StatusTooManyRequests, errTooManyMFAAttempts)
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt for the account and the IP and
+// locks whichever reached its limit. userID is 0 for unknown emails.
+func (as *AuthService) recordLoginFailure(ctx context.Context, email, ip string, userID int) {
+	as.recordAttempt(ctx, accountAttemptKey(email), "account", as.cfg.LoginThrottle.MaxAttempts, email, ip, userID)
+	as.recordAttempt(ctx, ipAttemptKey(ip), "ip", as.cfg.LoginThrottle.MaxAttemptsPerIP, email, ip, userID)
+}
+
+func (as *AuthService) recordAttempt(ctx context.Context, key, scope string, maxAttempts int, email, ip string, userID int) {
+	failures, err := as.repo.RecordLoginFailure(ctx, key, as.cfg.LoginThrottle.Window)
+	if err != nil {
+		return
+	}
+
+	lockout := lockoutDuration(failures, maxAttempts, as.cfg.LoginThrottle.LockoutBase, as.cfg.LoginThrottle.LockoutMax)
+	if lockout == 0 {
+		return
+	}
+
+	lockedUntil := time.Now().Add(lockout)
+	if err := as.repo.LockLogin(ctx, key, lockedUntil); err != nil {
+		return
+	}
+
+	as.logger.Warn("login locked out",
+		slog.String("scope", scope),
+		slog.String("ip", ip),
+		slog.Int("failures", failures),
+		slog.Duration("lockout", lockout),
+	)
+
+	entry := &audit.Entry{
+		Action:     "auth.login_lockout",
+		TargetType: "users",
+		IP:         ip,
+	}
+	details := map[string]interface{}{
+		"scope":        scope,
+		"failures":     failures,
+		"locked_until": lockedUntil.UTC(),
+	}
+	if email != "" {
+		details["email"] = email
+	}
+	entry.Details = audit.NewDetails(details)
+	if userID != 0 {
+		entry.TargetID = &userID
+	}
+	as.audit.Record(ctx, entry)
+}
+
+// lockoutDuration is zero below maxAttempts failures, then base, doubling
+// with each further failure up to max.
+func lockoutDuration(failures, maxAttempts int, base, max time.Duration) time.Duration {
+	if maxAttempts <= 0 || failures < maxAttempts {
+		return 0
+	}
+
+	lockout := base
+	for i := maxAttempts; i < failures && lockout < max; i++ {
+		lockout *= 2
+	}
+	if lockout > max {
+		lockout = max
+	}
+	return lockout
+}
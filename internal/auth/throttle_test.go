@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	const (
+		base = 30 * time.Second
+		max  = 15 * time.Minute
+	)
+
+	tests := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		want        time.Duration
+	}{
+		{"below limit", 4, 5, 0},
+		{"at limit", 5, 5, base},
+		{"one over", 6, 5, 2 * base},
+		{"three over", 8, 5, 8 * base},
+		{"capped", 20, 5, max},
+		{"far over", 1000, 5, max},
+		{"limit disabled", 100, 0, 0},
+		{"negative limit", 100, -1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lockoutDuration(tt.failures, tt.maxAttempts, base, max); got != tt.want {
+				t.Errorf("lockoutDuration(%d, %d) = %v, want %v", tt.failures, tt.maxAttempts, got, tt.want)
+			}
+		})
+	}
+}
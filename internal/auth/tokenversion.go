@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxCachedVersions is the cache size at which expired entries are swept. It
+// applies to the session cache too.
+const maxCachedVersions = 10000
+
+// TokenVersion returns the user's current token version. It is cached for
+// TokenVersionCacheTTL so AuthMiddleware doesn't hit the database on every
+// request. This instance forgets the cached version as soon as it bumps it.
+func (as *AuthService) TokenVersion(ctx context.Context, userID int) (int, error) {
+	if version, ok := as.versions.get(userID); ok {
+		return version, nil
+	}
+
+	version, err := as.repo.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	as.versions.set(userID, version)
+	return version, nil
+}
+
+// SessionActive reports whether the session an access token was issued for
+// still exists. Like TokenVersion it is cached for TokenVersionCacheTTL, and
+// this instance forgets a session as soon as it ends it.
+func (as *AuthService) SessionActive(ctx context.Context, userID int, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	if as.sessions.get(sessionID) {
+		return true, nil
+	}
+
+	active, err := as.repo.SessionExists(ctx, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	if active {
+		as.sessions.set(sessionID)
+	}
+	return active, nil
+}
+
+type versionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]versionEntry
+}
+
+type versionEntry struct {
+	version   int
+	expiresAt time.Time
+}
+
+func newVersionCache(ttl time.Duration) *versionCache {
+	return &versionCache{
+		ttl:     ttl,
+		entries: make(map[int]versionEntry),
+	}
+}
+
+func (vc *versionCache) get(userID int) (int, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	entry, ok := vc.entries[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, false
+	}
+	return entry.version, true
+}
+
+func (vc *versionCache) set(userID, version int) {
+	if vc.ttl <= 0 {
+		return
+	}
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	now := time.Now()
+	if len(vc.entries) >= maxCachedVersions {
+		for id, entry := range vc.entries {
+			if now.After(entry.expiresAt) {
+				delete(vc.entries, id)
+			}
+		}
+	}
+
+	vc.entries[userID] = versionEntry{version: version, expiresAt: now.Add(vc.ttl)}
+}
+
+func (vc *versionCache) forget(userID int) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	delete(vc.entries, userID)
+}
+
+// sessionCache remembers sessions known to exist. Ended sessions are not
+// cached, their tokens are rejected by the database lookup.
+type sessionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+}
+
+func newSessionCache(ttl time.Duration) *sessionCache {
+	return &sessionCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (sc *sessionCache) get(sessionID string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	expiresAt, ok := sc.entries[sessionID]
+	return ok && !time.Now().After(expiresAt)
+}
+
+func (sc *sessionCache) set(sessionID string) {
+	if sc.ttl <= 0 {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := time.Now()
+	if len(sc.entries) >= maxCachedVersions {
+		for id, expiresAt := range sc.entries {
+			if now.After(expiresAt) {
+				delete(sc.entries, id)
+			}
+		}
+	}
+
+	sc.entries[sessionID] = now.Add(sc.ttl)
+}
+
+func (sc *sessionCache) forget(sessionID string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	delete(sc.entries, sessionID)
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Name limits shared by registration and profile updates.
+const (
+	MinNameLength = 2
+	MaxNameLength = 100
+)
+
+const (
+	maxEmailLength    = 255
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordBytes = 72
+)
+
+// e164Pattern matches a phone number in E.164 format: a plus sign, the
+// country code and up to 15 digits in total.
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Normalize trims the name and email and lowercases the email, so the same
+// address can't be registered twice with different casing.
+func (r *RegisterReqBody) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Email = normalizeEmail(r.Email)
+	r.Phone = NormalizePhone(r.Phone)
+}
+
+func (r *RegisterReqBody) Validate() []FieldError {
+	var errs []FieldError
+
+	if n := utf8.RuneCountInString(r.Name); n < MinNameLength || n > MaxNameLength {
+		errs = append(errs, FieldError{Field: "name", Message: "must be between 2 and 100 characters"})
+	}
+
+	if !validEmail(r.Email) {
+		errs = append(errs, FieldError{Field: "email", Message: "must be a valid email address"})
+	}
+
+	if msg := checkPasswordStrength(r.Password); msg != "" {
+		errs = append(errs, FieldError{Field: "password", Message: msg})
+	}
+
+	if r.Phone != "" && !ValidPhone(r.Phone) {
+		errs = append(errs, FieldError{Field: "phone", Message: "must be a phone number in E.164 format, e.g. +992900123456"})
+	}
+
+	return errs
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone drops the spaces, dashes, dots and parentheses people use
+// to group digits.
+func NormalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(strings.TrimSpace(phone))
+}
+
+// ValidPhone reports whether a normalized phone is in E.164 format.
+func ValidPhone(phone string) bool {
+	return e164Pattern.MatchString(phone)
+}
+
+func validEmail(email string) bool {
+	if email == "" || len(email) > maxEmailLength {
+		return false
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return false
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	return strings.Contains(domain, ".")
+}
+
+// checkPasswordStrength returns why the password is too weak, or an empty
+// string if it is acceptable.
+func checkPasswordStrength(password string) string {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return "must be at least 8 characters long"
+	}
+
+	if len(password) > maxPasswordBytes {
+		return "must not be longer than 72 bytes"
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+
+	if !hasLetter || !hasDigit {
+		return "must contain at least one letter and one digit"
+	}
+
+	return ""
+}
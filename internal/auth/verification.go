@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/mailer"
+)
+
+// verificationResendCooldown limits how often a verification email can be
+// requested for the same account.
+const verificationResendCooldown = time.Minute
+
+func (as *AuthService) VerifyEmail(ctx context.Context, body *VerifyEmailReqBody) (*StatusResponse, *ErrorResponse) {
+	token := strings.TrimSpace(body.Token)
+	if token == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidToken)
+	}
+
+	userID, err := as.repo.VerifyEmail(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, errInvalidToken) {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	as.logger.Info("email verified",
+		slog.Int("user_id", userID),
+	)
+
+	return NewStatusResponse("verified"), nil
+}
+
+// ResendVerification sends a new verification email. It responds the same
+// way whether or not the address belongs to an unverified account, so it
+// can't be used to find out which emails are registered.
+func (as *AuthService) ResendVerification(ctx context.Context, body *ResendVerificationReqBody) (*StatusResponse, *ErrorResponse) {
+	response := NewStatusResponse("if the account exists and isn't verified, a new email has been sent")
+
+	email := normalizeEmail(body.Email)
+	if email == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("email is required"))
+	}
+
+	user, err := as.repo.GetUserByEmail(ctx, email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return response, nil
+	}
+
+	last, err := as.repo.LastVerificationTokenAt(ctx, user.ID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	if last != nil && time.Since(*last) < verificationResendCooldown {
+		return response, nil
+	}
+
+	as.sendVerification(ctx, user.ID, email)
+
+	return response, nil
+}
+
+// EmailVerified reports whether the user has confirmed their email.
+func (as *AuthService) EmailVerified(ctx context.Context, userID int) (bool, error) {
+	user, err := as.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+// sendVerification issues a verification token and emails it. Failures are
+// only logged: the account exists either way and the user can ask for
+// another email.
+func (as *AuthService) sendVerification(ctx context.Context, userID int, email string) {
+	token, err := newRandomToken()
+	if err != nil {
+		as.logger.Error("failed to generate verification token",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	err = as.repo.CreateVerificationToken(ctx, userID, hashToken(token), time.Now().Add(as.cfg.VerificationTokenTTL))
+	if err != nil {
+		return
+	}
+
+	err = as.mailer.Send(ctx, &mailer.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body:    tokenEmailBody("verify your Go-Ride email address", as.cfg.VerificationURL, token),
+	})
+	if err != nil {
+		as.logger.Error("failed to send verification email",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// tokenEmailBody tells the user to open baseURL with the token, or to enter
+// the token by hand when there is no URL to link to.
+func tokenEmailBody(action, baseURL, token string) string {
+	if baseURL != "" {
+		return "Open this link to " + action + ":\n\n" + withToken(baseURL, token) + "\n"
+	}
+	return "Use this code to " + action + ":\n\n" + token + "\n"
+}
+
+func withToken(rawURL, token string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var errInvalidAccessToken = errors.New("invalid token")
+
+// TokenVerifier checks the signature and registered claims of access tokens.
+type TokenVerifier interface {
+	Verify(tokenString string) (*Claims, error)
+}
+
+type jwtVerifier struct {
+	secret []byte
+	keys   *KeySet
+	// legacyUntil is when HS256 stops being accepted next to keys.
+	legacyUntil time.Time
+	parser      *jwt.Parser
+}
+
+// NewTokenVerifier accepts tokens signed by any key of cfg.SigningKeys. HS256
+// tokens signed with cfg.JWTSecret are only accepted without signing keys or,
+// while migrating to them, until cfg.AcceptLegacyHS256Until. Issuer and
+// audience must match and expiry is checked with cfg.Leeway of clock skew.
+func NewTokenVerifier(cfg Config) TokenVerifier {
+	methods := []string{"HS256"}
+	if cfg.SigningKeys != nil {
+		methods = []string{"RS256", "EdDSA"}
+		if !cfg.AcceptLegacyHS256Until.IsZero() {
+			methods = append(methods, "HS256")
+		}
+	}
+
+	return &jwtVerifier{
+		secret:      []byte(cfg.JWTSecret),
+		keys:        cfg.SigningKeys,
+		legacyUntil: cfg.AcceptLegacyHS256Until,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(methods),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithLeeway(cfg.Leeway),
+			jwt.WithExpirationRequired(),
+		),
+	}
+}
+
+func (v *jwtVerifier) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := v.parser.ParseWithClaims(tokenString, claims, v.key)
+	if err != nil || !token.Valid {
+		return nil, errInvalidAccessToken
+	}
+
+	return claims, nil
+}
+
+func (v *jwtVerifier) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(v.secret) == 0 || (v.keys != nil && !time.Now().Before(v.legacyUntil)) {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return v.secret, nil
+	}
+
+	if v.keys == nil {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.VerificationKey(kid, token.Method.Alg())
+}
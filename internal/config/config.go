@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,6 +13,9 @@ import (
 type Config struct {
 	Server struct {
 		Port string `mapstructure:"port"`
+		// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For
+		// is believed. Empty means the client IP is the peer address.
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
 	} `mapstructure:"server"`
 
 	Database struct {
@@ -22,10 +27,105 @@ type Config struct {
 	} `mapstructure:"db"`
 
 	JWT struct {
-		Secret          string
+		Secret string
+		// KeysDir holds <kid>.pem files. When set, access tokens are signed
+		// with the SigningKeyID key instead of Secret.
+		KeysDir      string `mapstructure:"keys_dir"`
+		SigningKeyID string `mapstructure:"signing_key_id"`
+		// AcceptLegacyHS256Until keeps HS256 tokens signed with Secret valid
+		// next to KeysDir until the given time, for migrating to signing
+		// keys. Zero means they aren't accepted.
+		AcceptLegacyHS256Until time.Time `mapstructure:"accept_legacy_hs256_until"`
+		Issuer                 string    `mapstructure:"issuer"`
+		Audience               string    `mapstructure:"audience"`
+		// Leeway is the clock skew allowed when checking token times.
+		Leeway          time.Duration `mapstructure:"leeway"`
 		AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
 		RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+		// CleanupInterval is how often expired refresh tokens and other
+		// short-lived records, such as idempotency keys, are purged.
+		CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+		// VersionCacheTTL is how long token versions are cached per instance.
+		VersionCacheTTL time.Duration `mapstructure:"version_cache_ttl"`
 	} `mapstructure:"jwt"`
+
+	LoginThrottle struct {
+		MaxAttempts      int           `mapstructure:"max_attempts"`
+		MaxAttemptsPerIP int           `mapstructure:"max_attempts_per_ip"`
+		Window           time.Duration `mapstructure:"window"`
+		LockoutBase      time.Duration `mapstructure:"lockout_base"`
+		LockoutMax       time.Duration `mapstructure:"lockout_max"`
+	} `mapstructure:"login_throttle"`
+
+	Fare struct {
+		Base      float64 `mapstructure:"base"`
+		PerKm     float64 `mapstructure:"per_km"`
+		PerMinute float64 `mapstructure:"per_minute"`
+		Minimum   float64 `mapstructure:"minimum"`
+	} `mapstructure:"fare"`
+
+	Rides struct {
+		MaxTripDistanceKm float64 `mapstructure:"max_trip_distance_km"`
+	} `mapstructure:"rides"`
+
+	Idempotency struct {
+		KeyTTL time.Duration `mapstructure:"key_ttl"`
+	} `mapstructure:"idempotency"`
+
+	Storage struct {
+		LocalDir string `mapstructure:"local_dir"`
+	} `mapstructure:"storage"`
+
+	Mail struct {
+		Driver       string `mapstructure:"driver"`
+		From         string `mapstructure:"from"`
+		SMTPHost     string `mapstructure:"smtp_host"`
+		SMTPPort     string `mapstructure:"smtp_port"`
+		SMTPUsername string `mapstructure:"smtp_username"`
+		SMTPPassword string
+		FileDir      string `mapstructure:"file_dir"`
+	} `mapstructure:"mail"`
+
+	EmailVerification struct {
+		TokenTTL time.Duration `mapstructure:"token_ttl"`
+		Required string        `mapstructure:"required"`
+		URL      string        `mapstructure:"url"`
+	} `mapstructure:"email_verification"`
+
+	PasswordReset struct {
+		TokenTTL time.Duration `mapstructure:"token_ttl"`
+		URL      string        `mapstructure:"url"`
+	} `mapstructure:"password_reset"`
+
+	SMS struct {
+		Driver  string `mapstructure:"driver"`
+		FileDir string `mapstructure:"file_dir"`
+	} `mapstructure:"sms"`
+
+	OTP struct {
+		CodeTTL time.Duration `mapstructure:"code_ttl"`
+		// ResendInterval is the minimum time between codes sent to one phone,
+		// MaxSends caps the codes sent to it within SendWindow.
+		ResendInterval time.Duration `mapstructure:"resend_interval"`
+		MaxSends       int           `mapstructure:"max_sends"`
+		SendWindow     time.Duration `mapstructure:"send_window"`
+		MaxAttempts    int           `mapstructure:"max_attempts"`
+	} `mapstructure:"otp"`
+
+	OIDC struct {
+		Providers []OIDCProvider `mapstructure:"providers"`
+		// StateTTL is how long a user may take to log in at the provider.
+		StateTTL time.Duration `mapstructure:"state_ttl"`
+	} `mapstructure:"oidc"`
+}
+
+type OIDCProvider struct {
+	Name         string `mapstructure:"name"`
+	Issuer       string `mapstructure:"issuer"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -36,6 +136,11 @@ func LoadConfig() (*Config, error) {
 
 	cfg := &Config{}
 	cfg.Server.Port = os.Getenv("SERVER_PORT")
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, proxy)
+		}
+	}
 
 	cfg.Database.Host = os.Getenv("POSTGRES_HOST")
 	cfg.Database.Port = os.Getenv("POSTGRES_PORT")
@@ -44,6 +149,27 @@ func LoadConfig() (*Config, error) {
 	cfg.Database.Password = os.Getenv("POSTGRES_PASSWORD")
 
 	cfg.JWT.Secret = os.Getenv("JWT_SECRET")
+	cfg.JWT.KeysDir = os.Getenv("JWT_KEYS_DIR")
+	cfg.JWT.SigningKeyID = os.Getenv("JWT_SIGNING_KEY_ID")
+	if cfg.JWT.KeysDir != "" && cfg.JWT.SigningKeyID == "" {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY_ID is required when JWT_KEYS_DIR is set")
+	}
+	if cfg.JWT.KeysDir == "" && cfg.JWT.Secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is required when JWT_KEYS_DIR is not set")
+	}
+	if legacyUntil := os.Getenv("JWT_ACCEPT_LEGACY_HS256"); legacyUntil != "" {
+		if cfg.JWT.KeysDir == "" || cfg.JWT.Secret == "" {
+			return nil, fmt.Errorf("JWT_ACCEPT_LEGACY_HS256 needs both JWT_KEYS_DIR and JWT_SECRET")
+		}
+		if cfg.JWT.AcceptLegacyHS256Until, err = time.Parse(time.RFC3339, legacyUntil); err != nil {
+			return nil, fmt.Errorf("JWT_ACCEPT_LEGACY_HS256 must be an RFC 3339 time: %w", err)
+		}
+	}
+	cfg.JWT.Issuer = getEnv("JWT_ISSUER", "go-ride")
+	cfg.JWT.Audience = getEnv("JWT_AUDIENCE", "go-ride")
+	if cfg.JWT.Leeway, err = getEnvDuration("JWT_LEEWAY", 30*time.Second); err != nil {
+		return nil, err
+	}
 
 	accesTokenTTL, err := time.ParseDuration(os.Getenv("JWT_ACCESS_TOKEN_TTL"))
 	if err != nil {
@@ -57,5 +183,186 @@ func LoadConfig() (*Config, error) {
 	}
 	cfg.JWT.RefreshTokenTTL = refreshTokenTTL
 
+	if cfg.JWT.CleanupInterval, err = getEnvDuration("REFRESH_TOKEN_CLEANUP_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.JWT.CleanupInterval <= 0 {
+		return nil, fmt.Errorf("REFRESH_TOKEN_CLEANUP_INTERVAL must be positive")
+	}
+	if cfg.JWT.VersionCacheTTL, err = getEnvDuration("TOKEN_VERSION_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginThrottle.MaxAttempts, err = getEnvInt("LOGIN_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.MaxAttemptsPerIP, err = getEnvInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.Window, err = getEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.LockoutBase, err = getEnvDuration("LOGIN_LOCKOUT_BASE", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.LockoutMax, err = getEnvDuration("LOGIN_LOCKOUT_MAX", 15*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.Fare.Base, err = getEnvFloat("FARE_BASE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Fare.PerKm, err = getEnvFloat("FARE_PER_KM", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Fare.PerMinute, err = getEnvFloat("FARE_PER_MINUTE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.Fare.Minimum, err = getEnvFloat("FARE_MINIMUM", 0); err != nil {
+		return nil, err
+	}
+
+	if cfg.Rides.MaxTripDistanceKm, err = getEnvFloat("RIDES_MAX_TRIP_DISTANCE_KM", 100); err != nil {
+		return nil, err
+	}
+
+	if cfg.Idempotency.KeyTTL, err = getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	cfg.Storage.LocalDir = getEnv("STORAGE_LOCAL_DIR", "./data/uploads")
+
+	cfg.Mail.Driver = getEnv("MAIL_DRIVER", "log")
+	if cfg.Mail.Driver != "smtp" && cfg.Mail.Driver != "file" && cfg.Mail.Driver != "log" {
+		return nil, fmt.Errorf("MAIL_DRIVER must be smtp, file or log")
+	}
+	cfg.Mail.From = getEnv("MAIL_FROM", "no-reply@go-ride.local")
+	cfg.Mail.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.Mail.SMTPPort = getEnv("SMTP_PORT", "587")
+	cfg.Mail.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.Mail.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.Mail.FileDir = getEnv("MAIL_FILE_DIR", "./data/mail")
+
+	if cfg.EmailVerification.TokenTTL, err = getEnvDuration("EMAIL_VERIFICATION_TOKEN_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.EmailVerification.Required = os.Getenv("EMAIL_VERIFICATION_REQUIRED")
+	if r := cfg.EmailVerification.Required; r != "" && r != "login" && r != "rides" {
+		return nil, fmt.Errorf("EMAIL_VERIFICATION_REQUIRED must be empty, login or rides")
+	}
+	cfg.EmailVerification.URL = os.Getenv("EMAIL_VERIFICATION_URL")
+
+	if cfg.PasswordReset.TokenTTL, err = getEnvDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.PasswordReset.URL = os.Getenv("PASSWORD_RESET_URL")
+
+	cfg.SMS.Driver = getEnv("SMS_DRIVER", "log")
+	if cfg.SMS.Driver != "file" && cfg.SMS.Driver != "log" {
+		return nil, fmt.Errorf("SMS_DRIVER must be file or log")
+	}
+	cfg.SMS.FileDir = getEnv("SMS_FILE_DIR", "./data/sms")
+
+	if cfg.OTP.CodeTTL, err = getEnvDuration("OTP_CODE_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.OTP.ResendInterval, err = getEnvDuration("OTP_RESEND_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.OTP.MaxSends, err = getEnvInt("OTP_MAX_SENDS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.OTP.SendWindow, err = getEnvDuration("OTP_SEND_WINDOW", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.OTP.MaxAttempts, err = getEnvInt("OTP_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+
+	if cfg.OIDC.Providers, err = loadOIDCProviders(os.Getenv("OIDC_PROVIDERS")); err != nil {
+		return nil, err
+	}
+	if cfg.OIDC.StateTTL, err = getEnvDuration("OIDC_STATE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// loadOIDCProviders reads the providers listed in names, separated by
+// commas. Provider "google" is configured with OIDC_GOOGLE_ISSUER,
+// OIDC_GOOGLE_CLIENT_ID, OIDC_GOOGLE_CLIENT_SECRET, OIDC_GOOGLE_REDIRECT_URL
+// and optionally OIDC_GOOGLE_SCOPES.
+func loadOIDCProviders(names string) ([]OIDCProvider, error) {
+	var providers []OIDCProvider
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s: %w", key, err)
+	}
+	return parsed, nil
+}
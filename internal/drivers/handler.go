@@ -0,0 +1,262 @@
+package drivers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DriverServiceInterface interface {
+	Apply(ctx context.Context, userID int) (*Application, *ErrorResponse)
+	GetMyApplication(ctx context.Context, userID int) (*Application, *ErrorResponse)
+	UploadDocument(ctx context.Context, userID int, docType string, file io.Reader, size int64) (*Document, *ErrorResponse)
+	ListApplications(ctx context.Context, status string) (*ApplicationsResponse, *ErrorResponse)
+	GetApplication(ctx context.Context, applicationID int) (*Application, *ErrorResponse)
+	OpenDocument(ctx context.Context, applicationID int, docType string) (io.ReadCloser, *Document, *ErrorResponse)
+	Approve(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse)
+	Reject(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse)
+	Suspend(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse)
+}
+
+type DriverHandler struct {
+	service DriverServiceInterface
+}
+
+func NewDriverHandler(service DriverServiceInterface) *DriverHandler {
+	return &DriverHandler{
+		service: service,
+	}
+}
+
+// @Summary      Apply to drive
+// @Description  Start a driver application for the current user. Documents are uploaded separately
+// @Tags         drivers
+// @Produce      json
+// @Success      200  {object}  Application
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /drivers/application [post]
+func (dh *DriverHandler) Apply(c *gin.Context) {
+	application, err := dh.service.Apply(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, application)
+}
+
+// @Summary      Get my application
+// @Description  Get the driver application of the current user with its documents
+// @Tags         drivers
+// @Produce      json
+// @Success      200  {object}  Application
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /drivers/application [get]
+func (dh *DriverHandler) GetMyApplication(c *gin.Context) {
+	application, err := dh.service.GetMyApplication(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, application)
+}
+
+// @Summary      Upload document
+// @Description  Upload or replace a document of the current user's application. Accepts PDF, JPEG or PNG up to 10 MB. Uploading to a rejected application sends it back for review
+// @Tags         drivers
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        type  path      string  true  "Document type"  Enums(LICENSE, REGISTRATION, INSURANCE)
+// @Param        file  formData  file    true  "Document file"
+// @Success      200   {object}  Document
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /drivers/application/documents/{type} [put]
+func (dh *DriverHandler) UploadDocument(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize+1<<20)
+
+	fileHeader, formErr := c.FormFile("file")
+	if formErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	file, openErr := fileHeader.Open()
+	if openErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	defer file.Close()
+
+	document, err := dh.service.UploadDocument(c, c.GetInt("userID"), c.Param("type"), file, fileHeader.Size)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// @Summary      List driver applications
+// @Description  Get driver applications, oldest first, optionally filtered by status
+// @Tags         admin
+// @Produce      json
+// @Param        status  query     string  false  "Application status"  Enums(PENDING, APPROVED, REJECTED, SUSPENDED)
+// @Success      200     {object}  ApplicationsResponse
+// @Failure      400     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications [get]
+func (dh *DriverHandler) ListApplications(c *gin.Context) {
+	applications, err := dh.service.ListApplications(c, c.Query("status"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, applications)
+}
+
+// @Summary      Get driver application
+// @Description  Get a driver application with its documents
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Application ID"
+// @Success      200  {object}  Application
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications/{id} [get]
+func (dh *DriverHandler) GetApplication(c *gin.Context) {
+	applicationID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid application ID")
+		return
+	}
+
+	application, err := dh.service.GetApplication(c, applicationID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, application)
+}
+
+// @Summary      Download document
+// @Description  Download a document of a driver application for review
+// @Tags         admin
+// @Produce      application/octet-stream
+// @Param        id    path      int     true  "Application ID"
+// @Param        type  path      string  true  "Document type"  Enums(LICENSE, REGISTRATION, INSURANCE)
+// @Success      200   {file}    file
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications/{id}/documents/{type} [get]
+func (dh *DriverHandler) GetDocument(c *gin.Context) {
+	applicationID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid application ID")
+		return
+	}
+
+	r, document, err := dh.service.OpenDocument(c, applicationID, c.Param("type"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, document.Size, document.ContentType, r, nil)
+}
+
+// @Summary      Approve driver application
+// @Description  Approve a pending application or reinstate a suspended driver. All documents must be uploaded
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int            true   "Application ID"
+// @Param        body  body      ReviewRequest  false  "Review note"
+// @Success      200   {object}  Application
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications/{id}/approve [post]
+func (dh *DriverHandler) Approve(c *gin.Context) {
+	dh.review(c, dh.service.Approve)
+}
+
+// @Summary      Reject driver application
+// @Description  Reject a pending application. The applicant can upload new documents to resubmit it
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int            true   "Application ID"
+// @Param        body  body      ReviewRequest  false  "Review note"
+// @Success      200   {object}  Application
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications/{id}/reject [post]
+func (dh *DriverHandler) Reject(c *gin.Context) {
+	dh.review(c, dh.service.Reject)
+}
+
+// @Summary      Suspend driver
+// @Description  Suspend an approved driver. Suspended drivers can't search, take or complete rides
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int            true   "Application ID"
+// @Param        body  body      ReviewRequest  false  "Review note"
+// @Success      200   {object}  Application
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/driver-applications/{id}/suspend [post]
+func (dh *DriverHandler) Suspend(c *gin.Context) {
+	dh.review(c, dh.service.Suspend)
+}
+
+func (dh *DriverHandler) review(c *gin.Context, action func(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse)) {
+	applicationID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid application ID")
+		return
+	}
+
+	var body ReviewRequest
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+			return
+		}
+	}
+
+	application, err := action(c, applicationID, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, application)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
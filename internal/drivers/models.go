@@ -0,0 +1,96 @@
+package drivers
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	PendingStatus   = "PENDING"
+	ApprovedStatus  = "APPROVED"
+	RejectedStatus  = "REJECTED"
+	SuspendedStatus = "SUSPENDED"
+)
+
+const (
+	LicenseDocument      = "LICENSE"
+	RegistrationDocument = "REGISTRATION"
+	InsuranceDocument    = "INSURANCE"
+)
+
+var DocumentTypes = []string{LicenseDocument, RegistrationDocument, InsuranceDocument}
+
+const maxDocumentSize = 10 << 20
+
+var allowedContentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+var (
+	ErrApplicationNotFound = errors.New("driver application not found")
+	errDocumentNotFound    = errors.New("document not found")
+	errApplicationExists   = errors.New("you have already applied")
+	errInvalidTransition   = errors.New("application can't be moved to this status")
+	errDocumentsMissing    = errors.New("application is missing required documents")
+	errDocumentsLocked     = errors.New("documents can only be changed while the application is pending or rejected")
+	errUnknownDocumentType = errors.New("document type must be one of LICENSE, REGISTRATION, INSURANCE")
+	errDocumentTooLarge    = errors.New("document must not be larger than 10 MB")
+	errDocumentFormat      = errors.New("document must be a PDF, JPEG or PNG file")
+)
+
+type Application struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Status     string     `json:"status" db:"status"`
+	ReviewNote *string    `json:"review_note,omitempty" db:"review_note"`
+	ReviewedBy *int       `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	Documents  []Document `json:"documents" db:"-"`
+}
+
+type Document struct {
+	ID            int       `json:"id" db:"id"`
+	ApplicationID int       `json:"application_id" db:"application_id"`
+	Type          string    `json:"type" db:"type"`
+	StorageKey    string    `json:"-" db:"storage_key"`
+	ContentType   string    `json:"content_type" db:"content_type"`
+	Size          int64     `json:"size" db:"size"`
+	UploadedAt    time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
+
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+type ApplicationsResponse struct {
+	Applications []Application `json:"applications"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
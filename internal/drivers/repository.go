@@ -0,0 +1,251 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	applicationColumns  = "id, user_id, status, review_note, reviewed_by, reviewed_at, created_at, updated_at"
+	documentColumns     = "id, application_id, type, storage_key, content_type, size, uploaded_at"
+	uniqueViolationCode = "23505"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) CreateApplication(ctx context.Context, userID int) (*Application, error) {
+	var application Application
+
+	err := pr.db.GetContext(ctx, &application, "INSERT INTO driver_applications (user_id, status) VALUES ($1, $2) RETURNING "+applicationColumns, userID, PendingStatus)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			return nil, errApplicationExists
+		}
+		pr.logger.Error("failed to create driver application",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create driver application: %w", err)
+	}
+
+	return &application, nil
+}
+
+func (pr *postgresRepo) GetApplicationByID(ctx context.Context, applicationID int) (*Application, error) {
+	var application Application
+
+	err := pr.db.GetContext(ctx, &application, "SELECT "+applicationColumns+" FROM driver_applications WHERE id = $1", applicationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrApplicationNotFound
+		}
+		pr.logger.Error("failed to get driver application",
+			slog.Int("application_id", applicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get driver application: %w", err)
+	}
+
+	return &application, nil
+}
+
+func (pr *postgresRepo) GetApplicationByUserID(ctx context.Context, userID int) (*Application, error) {
+	var application Application
+
+	err := pr.db.GetContext(ctx, &application, "SELECT "+applicationColumns+" FROM driver_applications WHERE user_id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrApplicationNotFound
+		}
+		pr.logger.Error("failed to get driver application",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get driver application: %w", err)
+	}
+
+	return &application, nil
+}
+
+func (pr *postgresRepo) ListApplications(ctx context.Context, status string) ([]Application, error) {
+	applications := []Application{}
+
+	query := "SELECT " + applicationColumns + " FROM driver_applications"
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = $1"
+		args = append(args, status)
+	}
+	query += " ORDER BY updated_at"
+
+	err := pr.db.SelectContext(ctx, &applications, query, args...)
+	if err != nil {
+		pr.logger.Error("failed to list driver applications",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list driver applications: %w", err)
+	}
+
+	return applications, nil
+}
+
+func (pr *postgresRepo) ListDocuments(ctx context.Context, applicationID int) ([]Document, error) {
+	documents := []Document{}
+
+	err := pr.db.SelectContext(ctx, &documents, "SELECT "+documentColumns+" FROM driver_documents WHERE application_id = $1 ORDER BY type", applicationID)
+	if err != nil {
+		pr.logger.Error("failed to list driver documents",
+			slog.Int("application_id", applicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list driver documents: %w", err)
+	}
+
+	return documents, nil
+}
+
+func (pr *postgresRepo) GetDocument(ctx context.Context, applicationID int, docType string) (*Document, error) {
+	var document Document
+
+	err := pr.db.GetContext(ctx, &document, "SELECT "+documentColumns+" FROM driver_documents WHERE application_id = $1 AND type = $2", applicationID, docType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errDocumentNotFound
+		}
+		pr.logger.Error("failed to get driver document",
+			slog.Int("application_id", applicationID),
+			slog.String("type", docType),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get driver document: %w", err)
+	}
+
+	return &document, nil
+}
+
+// SaveDocument stores the document metadata, replacing a previous upload of
+// the same type. The storage key of the replaced upload is returned so its
+// blob can be removed.
+func (pr *postgresRepo) SaveDocument(ctx context.Context, document *Document) (*Document, string, error) {
+	var previousKey sql.NullString
+	err := pr.db.GetContext(ctx, &previousKey, "SELECT storage_key FROM driver_documents WHERE application_id = $1 AND type = $2", document.ApplicationID, document.Type)
+	if err != nil && err != sql.ErrNoRows {
+		pr.logger.Error("failed to get driver document",
+			slog.Int("application_id", document.ApplicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, "", fmt.Errorf("failed to save driver document: %w", err)
+	}
+
+	var saved Document
+	err = pr.db.GetContext(ctx, &saved, `INSERT INTO driver_documents (application_id, type, storage_key, content_type, size) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (application_id, type) DO UPDATE SET storage_key = EXCLUDED.storage_key, content_type = EXCLUDED.content_type, size = EXCLUDED.size, uploaded_at = now()
+		RETURNING `+documentColumns,
+		document.ApplicationID, document.Type, document.StorageKey, document.ContentType, document.Size,
+	)
+	if err != nil {
+		pr.logger.Error("failed to save driver document",
+			slog.Int("application_id", document.ApplicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, "", fmt.Errorf("failed to save driver document: %w", err)
+	}
+
+	return &saved, previousKey.String, nil
+}
+
+// UpdateStatus moves the application to status if it is currently in one of
+// from. Approving an application also gives the user the DRIVER role and
+// bumps their token version, so tokens issued with the old role stop working.
+func (pr *postgresRepo) UpdateStatus(ctx context.Context, applicationID int, from []string, status string, reviewerID *int, note *string) (*Application, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update driver application",
+			slog.Int("application_id", applicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update driver application: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var application Application
+	err = tx.GetContext(ctx, &application, `UPDATE driver_applications
+		SET status = $1, review_note = $2, reviewed_by = $3, reviewed_at = CASE WHEN $3::INTEGER IS NULL THEN reviewed_at ELSE now() END, updated_at = now()
+		WHERE id = $4 AND status = ANY($5)
+		RETURNING `+applicationColumns,
+		status, note, reviewerID, applicationID, pq.Array(from),
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if _, getErr := pr.GetApplicationByID(ctx, applicationID); getErr != nil {
+				return nil, getErr
+			}
+			return nil, errInvalidTransition
+		}
+		pr.logger.Error("failed to update driver application",
+			slog.Int("application_id", applicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update driver application: %w", err)
+	}
+
+	if status == ApprovedStatus {
+		_, err = tx.ExecContext(ctx, "UPDATE users SET role = $1, token_version = token_version + 1 WHERE id = $2 AND role = $3", rbac.RoleDriver, application.UserID, rbac.RoleUser)
+		if err != nil {
+			pr.logger.Error("failed to promote user to driver",
+				slog.Int("user_id", application.UserID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to update driver application: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update driver application",
+			slog.Int("application_id", applicationID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update driver application: %w", err)
+	}
+
+	return &application, nil
+}
+
+func (pr *postgresRepo) GetStatusByUserID(ctx context.Context, userID int) (string, error) {
+	var status string
+
+	err := pr.db.GetContext(ctx, &status, "SELECT status FROM driver_applications WHERE user_id = $1", userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrApplicationNotFound
+		}
+		pr.logger.Error("failed to get driver status",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return "", fmt.Errorf("failed to get driver status: %w", err)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,252 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/storage"
+)
+
+type RepositoryInterface interface {
+	CreateApplication(ctx context.Context, userID int) (*Application, error)
+	GetApplicationByID(ctx context.Context, applicationID int) (*Application, error)
+	GetApplicationByUserID(ctx context.Context, userID int) (*Application, error)
+	ListApplications(ctx context.Context, status string) ([]Application, error)
+	ListDocuments(ctx context.Context, applicationID int) ([]Document, error)
+	GetDocument(ctx context.Context, applicationID int, docType string) (*Document, error)
+	SaveDocument(ctx context.Context, document *Document) (*Document, string, error)
+	UpdateStatus(ctx context.Context, applicationID int, from []string, status string, reviewerID *int, note *string) (*Application, error)
+	GetStatusByUserID(ctx context.Context, userID int) (string, error)
+}
+
+type DriverService struct {
+	repo   RepositoryInterface
+	blobs  storage.BlobStore
+	logger *slog.Logger
+}
+
+func NewDriverService(repository RepositoryInterface, blobs storage.BlobStore, logger *slog.Logger) *DriverService {
+	return &DriverService{
+		repo:   repository,
+		blobs:  blobs,
+		logger: logger,
+	}
+}
+
+func (ds *DriverService) Apply(ctx context.Context, userID int) (*Application, *ErrorResponse) {
+	application, err := ds.repo.CreateApplication(ctx, userID)
+	if err != nil {
+		return nil, applicationErrorResponse(err)
+	}
+	application.Documents = []Document{}
+
+	ds.logger.Info("driver application created",
+		slog.Int("application_id", application.ID),
+		slog.Int("user_id", userID),
+	)
+
+	return application, nil
+}
+
+func (ds *DriverService) GetMyApplication(ctx context.Context, userID int) (*Application, *ErrorResponse) {
+	application, err := ds.repo.GetApplicationByUserID(ctx, userID)
+	if err != nil {
+		return nil, applicationErrorResponse(err)
+	}
+	return ds.withDocuments(ctx, application)
+}
+
+func (ds *DriverService) GetApplication(ctx context.Context, applicationID int) (*Application, *ErrorResponse) {
+	application, err := ds.repo.GetApplicationByID(ctx, applicationID)
+	if err != nil {
+		return nil, applicationErrorResponse(err)
+	}
+	return ds.withDocuments(ctx, application)
+}
+
+func (ds *DriverService) ListApplications(ctx context.Context, status string) (*ApplicationsResponse, *ErrorResponse) {
+	status = strings.ToUpper(status)
+	if status != "" && !slices.Contains([]string{PendingStatus, ApprovedStatus, RejectedStatus, SuspendedStatus}, status) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("unknown application status"))
+	}
+
+	applications, err := ds.repo.ListApplications(ctx, status)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &ApplicationsResponse{Applications: applications}, nil
+}
+
+// UploadDocument stores a document for the user's application. Uploading to
+// a rejected application sends it back to the review queue.
+func (ds *DriverService) UploadDocument(ctx context.Context, userID int, docType string, file io.Reader, size int64) (*Document, *ErrorResponse) {
+	docType = strings.ToUpper(docType)
+	if !slices.Contains(DocumentTypes, docType) {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errUnknownDocumentType)
+	}
+
+	if size > maxDocumentSize {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errDocumentTooLarge)
+	}
+
+	application, err := ds.repo.GetApplicationByUserID(ctx, userID)
+	if err != nil {
+		return nil, applicationErrorResponse(err)
+	}
+
+	if application.Status != PendingStatus && application.Status != RejectedStatus {
+		return nil, NewErrorResponseWithStatus(http.StatusConflict, errDocumentsLocked)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("failed to read document"))
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := allowedContentTypes[contentType]
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errDocumentFormat)
+	}
+
+	key := fmt.Sprintf("driver-documents/%d/%s-%d%s", application.ID, strings.ToLower(docType), time.Now().UnixNano(), ext)
+	if err := ds.blobs.Put(ctx, key, io.MultiReader(bytes.NewReader(head), file)); err != nil {
+		ds.logger.Error("failed to store driver document",
+			slog.Int("application_id", application.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, NewErrorResponse(fmt.Errorf("failed to store document"))
+	}
+
+	document, previousKey, err := ds.repo.SaveDocument(ctx, &Document{
+		ApplicationID: application.ID,
+		Type:          docType,
+		StorageKey:    key,
+		ContentType:   contentType,
+		Size:          size,
+	})
+	if err != nil {
+		_ = ds.blobs.Delete(ctx, key)
+		return nil, NewErrorResponse(err)
+	}
+
+	if previousKey != "" {
+		if err := ds.blobs.Delete(ctx, previousKey); err != nil {
+			ds.logger.Error("failed to delete replaced driver document",
+				slog.Int("application_id", application.ID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	if application.Status == RejectedStatus {
+		if _, err := ds.repo.UpdateStatus(ctx, application.ID, []string{RejectedStatus}, PendingStatus, nil, nil); err != nil {
+			return nil, applicationErrorResponse(err)
+		}
+	}
+
+	ds.logger.Info("driver document uploaded",
+		slog.Int("application_id", application.ID),
+		slog.String("type", docType),
+	)
+
+	return document, nil
+}
+
+// OpenDocument returns the document contents for review. The caller must
+// close the reader.
+func (ds *DriverService) OpenDocument(ctx context.Context, applicationID int, docType string) (io.ReadCloser, *Document, *ErrorResponse) {
+	document, err := ds.repo.GetDocument(ctx, applicationID, strings.ToUpper(docType))
+	if err != nil {
+		return nil, nil, applicationErrorResponse(err)
+	}
+
+	r, err := ds.blobs.Get(ctx, document.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, nil, NewErrorResponseWithStatus(http.StatusNotFound, errDocumentNotFound)
+		}
+		return nil, nil, NewErrorResponse(err)
+	}
+
+	return r, document, nil
+}
+
+func (ds *DriverService) Approve(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse) {
+	documents, err := ds.repo.ListDocuments(ctx, applicationID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	for _, docType := range DocumentTypes {
+		if !slices.ContainsFunc(documents, func(d Document) bool { return d.Type == docType }) {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, errDocumentsMissing)
+		}
+	}
+
+	return ds.review(ctx, applicationID, []string{PendingStatus, SuspendedStatus}, ApprovedStatus, reviewerID, body)
+}
+
+func (ds *DriverService) Reject(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse) {
+	return ds.review(ctx, applicationID, []string{PendingStatus}, RejectedStatus, reviewerID, body)
+}
+
+func (ds *DriverService) Suspend(ctx context.Context, applicationID, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse) {
+	return ds.review(ctx, applicationID, []string{ApprovedStatus}, SuspendedStatus, reviewerID, body)
+}
+
+// DriverStatus returns the status of the user's driver application, or
+// ErrApplicationNotFound if the user never applied.
+func (ds *DriverService) DriverStatus(ctx context.Context, userID int) (string, error) {
+	return ds.repo.GetStatusByUserID(ctx, userID)
+}
+
+func (ds *DriverService) review(ctx context.Context, applicationID int, from []string, status string, reviewerID int, body *ReviewRequest) (*Application, *ErrorResponse) {
+	var note *string
+	if trimmed := strings.TrimSpace(body.Note); trimmed != "" {
+		note = &trimmed
+	}
+
+	application, err := ds.repo.UpdateStatus(ctx, applicationID, from, status, &reviewerID, note)
+	if err != nil {
+		return nil, applicationErrorResponse(err)
+	}
+
+	ds.logger.Info("driver application reviewed",
+		slog.Int("application_id", applicationID),
+		slog.Int("reviewer_id", reviewerID),
+		slog.String("status", status),
+	)
+
+	return ds.withDocuments(ctx, application)
+}
+
+func (ds *DriverService) withDocuments(ctx context.Context, application *Application) (*Application, *ErrorResponse) {
+	documents, err := ds.repo.ListDocuments(ctx, application.ID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	application.Documents = documents
+	return application, nil
+}
+
+func applicationErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, ErrApplicationNotFound), errors.Is(err, errDocumentNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errApplicationExists), errors.Is(err, errInvalidTransition):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
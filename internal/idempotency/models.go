@@ -0,0 +1,19 @@
+package idempotency
+
+import "time"
+
+type Record struct {
+	UserID      int       `db:"user_id"`
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"`
+	StatusCode  *int      `db:"status_code"`
+	ContentType *string   `db:"content_type"`
+	Body        []byte    `db:"response_body"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Completed reports whether the original request has finished and its
+// response can be replayed.
+func (r *Record) Completed() bool {
+	return r.StatusCode != nil
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Store interface {
+	Reserve(ctx context.Context, userID int, key, requestHash string) (*Record, bool, error)
+	Complete(ctx context.Context, userID int, key string, statusCode int, contentType string, body []byte) error
+	Release(ctx context.Context, userID int, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, ttl time.Duration, logger *slog.Logger) Store {
+	return &postgresRepo{db, ttl, logger}
+}
+
+// Reserve claims the key for the user. When the key is already taken the
+// existing record is returned and reserved is false.
+func (pr *postgresRepo) Reserve(ctx context.Context, userID int, key, requestHash string) (*Record, bool, error) {
+	_, err := pr.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND created_at < $3", userID, key, time.Now().Add(-pr.ttl))
+	if err != nil {
+		pr.logger.Error("failed to delete expired idempotency key",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	res, err := pr.db.ExecContext(ctx, "INSERT INTO idempotency_keys (user_id, key, request_hash) VALUES ($1, $2, $3) ON CONFLICT (user_id, key) DO NOTHING", userID, key, requestHash)
+	if err != nil {
+		pr.logger.Error("failed to reserve idempotency key",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if inserted == 1 {
+		return nil, true, nil
+	}
+
+	var record Record
+	err = pr.db.GetContext(ctx, &record, "SELECT user_id, key, request_hash, status_code, content_type, response_body, created_at FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+	if err != nil {
+		pr.logger.Error("failed to get idempotency key",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	return &record, false, nil
+}
+
+func (pr *postgresRepo) Complete(ctx context.Context, userID int, key string, statusCode int, contentType string, body []byte) error {
+	_, err := pr.db.ExecContext(ctx, "UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3 WHERE user_id = $4 AND key = $5", statusCode, contentType, body, userID, key)
+	if err != nil {
+		pr.logger.Error("failed to save idempotent response",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (pr *postgresRepo) Release(ctx context.Context, userID int, key string) error {
+	_, err := pr.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+	if err != nil {
+		pr.logger.Error("failed to release idempotency key",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired purges keys older than the TTL. Reserve only drops an expired
+// key when it is reused, so the rest are left to this.
+func (pr *postgresRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-pr.ttl))
+	if err != nil {
+		pr.logger.Error("failed to delete expired idempotency keys",
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	deleted, _ := res.RowsAffected()
+	return deleted, nil
+}
+
+// RunCleanup purges expired keys every interval until ctx is done.
+func RunCleanup(ctx context.Context, store Store, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := store.DeleteExpired(ctx); err == nil && deleted > 0 {
+				logger.Info("expired idempotency keys purged",
+					slog.Int64("count", deleted),
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every message to an .eml file in dir instead of
+// sending it. Meant for development.
+func NewFileMailer(dir, from string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &fileMailer{dir: dir, from: from}, nil
+}
+
+func (fm *fileMailer) Send(ctx context.Context, msg *Message) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(msg.To))
+	if err := os.WriteFile(filepath.Join(fm.dir, name), buildMessage(fm.from, msg), 0o640); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+type logMailer struct {
+	logger *slog.Logger
+}
+
+// NewLogMailer writes every message to the log instead of sending it. Meant
+// for development: message bodies contain secrets such as verification tokens.
+func NewLogMailer(logger *slog.Logger) Mailer {
+	return &logMailer{logger: logger}
+}
+
+func (lm *logMailer) Send(ctx context.Context, msg *Message) error {
+	lm.logger.Info("email",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
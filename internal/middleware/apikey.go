@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/apikeys"
+	"github.com/AzizovHikmatullo/go-ride/internal/auth"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, rawKey string) (*apikeys.Principal, error)
+}
+
+// AuthOrAPIKeyMiddleware accepts an API key in the X-API-Key header and
+// otherwise behaves like AuthMiddleware. A key's permissions are its scopes,
+// limited to what its user's role may do.
+func AuthOrAPIKeyMiddleware(verifier auth.TokenVerifier, versions TokenVersionProvider, keys APIKeyAuthenticator) gin.HandlerFunc {
+	jwtAuth := AuthMiddleware(verifier, versions)
+
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader(apiKeyHeader)
+		if rawKey == "" {
+			jwtAuth(c)
+			return
+		}
+
+		principal, err := keys.Authenticate(c, rawKey)
+		if err != nil {
+			if errors.Is(err, apikeys.ErrInvalidAPIKey) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check api key"})
+			return
+		}
+
+		c.Set("userID", principal.UserID)
+		c.Set("role", principal.Role)
+		c.Set("permissions", rbac.Intersect(principal.Scopes, rbac.Permissions(principal.Role)))
+		c.Set("apiKeyID", principal.KeyID)
+		c.Set("apiKeyScopes", principal.Scopes)
+		// Keys are issued by admins and don't go through two-factor
+		// authentication.
+		c.Set("mfa", true)
+
+		c.Next()
+	}
+}
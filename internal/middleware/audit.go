@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/audit"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *audit.Entry)
+}
+
+// AuditMiddleware writes an audit log entry for every successful request
+// that changes state. The action is the method and route pattern, e.g.
+// "POST /admin/rides/:id/cancel", and the target is the resource the route
+// belongs to. It must run after AuthMiddleware.
+func AuditMiddleware(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Request.Method == http.MethodGet || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		entry := &audit.Entry{
+			Action:     c.Request.Method + " " + c.FullPath(),
+			TargetType: routeResource(c.FullPath()),
+			IP:         c.ClientIP(),
+			Details: audit.NewDetails(map[string]interface{}{
+				"path":   c.Request.URL.Path,
+				"status": c.Writer.Status(),
+			}),
+		}
+
+		if actorID := c.GetInt("userID"); actorID != 0 {
+			entry.ActorID = &actorID
+		}
+
+		if targetID, err := strconv.Atoi(c.Param("id")); err == nil {
+			entry.TargetID = &targetID
+		}
+
+		recorder.Record(c, entry)
+	}
+}
+
+// routeResource returns the first segment after the group prefix, so
+// "/admin/users/:id/suspend" gives "users".
+func routeResource(route string) string {
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[1]
+}
@@ -1,16 +1,24 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/AzizovHikmatullo/go-ride/internal/auth"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+type TokenVersionProvider interface {
+	TokenVersion(ctx context.Context, userID int) (int, error)
+	SessionActive(ctx context.Context, userID int, sessionID string) (bool, error)
+}
+
+// AuthMiddleware accepts access tokens that pass verifier, whose version
+// still matches the user's token version and whose session hasn't ended.
+func AuthMiddleware(verifier auth.TokenVerifier, versions TokenVersionProvider) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,29 +32,43 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(
-			tokenStr,
-			&auth.Claims{},
-			func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return []byte(os.Getenv("JWT_SECRET")), nil
-			},
-		)
-		if err != nil || !token.Valid {
+		claims, err := verifier.Verify(tokenStr)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
-		claims, ok := token.Claims.(*auth.Claims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid claims"})
+		version, err := versions.TokenVersion(c, claims.UserID)
+		if err != nil {
+			if errors.Is(err, auth.ErrUserNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+			return
+		}
+
+		if claims.TokenVersion != version {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+
+		active, err := versions.SessionActive(c, claims.UserID, claims.SessionID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
 			return
 		}
 
 		c.Set("userID", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("permissions", rbac.Permissions(claims.Role))
+		c.Set("sessionID", claims.SessionID)
+		c.Set("tokenID", claims.ID)
+		c.Set("mfa", claims.MFA)
 
 		c.Next()
 	}
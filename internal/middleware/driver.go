@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/drivers"
+	"github.com/gin-gonic/gin"
+)
+
+type DriverStatusProvider interface {
+	DriverStatus(ctx context.Context, userID int) (string, error)
+}
+
+// RequireApprovedDriver lets through only drivers whose application has been
+// approved and not suspended since. It must run after AuthMiddleware.
+func RequireApprovedDriver(provider DriverStatusProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := provider.DriverStatus(c, c.GetInt("userID"))
+		if err != nil {
+			if errors.Is(err, drivers.ErrApplicationNotFound) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "driver application required"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check driver status"})
+			return
+		}
+
+		if status != drivers.ApprovedStatus {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "driver is not approved"})
+			return
+		}
+
+		c.Next()
+	}
+}
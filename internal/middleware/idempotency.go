@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the stored response when a POST request is
+// retried with the same Idempotency-Key. Keys are scoped per user, so it must
+// run after AuthMiddleware. Requests without the header are passed through.
+func IdempotencyMiddleware(store idempotency.Store, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := c.GetInt("userID")
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+
+		record, reserved, err := store.Reserve(c, userID, key, requestHash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to process Idempotency-Key"})
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case !record.Completed():
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				contentType := "application/json; charset=utf-8"
+				if record.ContentType != nil {
+					contentType = *record.ContentType
+				}
+				c.Header(idempotentReplayHeader, "true")
+				c.Data(*record.StatusCode, contentType, record.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// The response has already been sent, so the record is saved even if
+		// the client has gone away in the meantime.
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		// A panicking handler must not leave the key reserved, or every retry
+		// would get 409 until the key expires.
+		defer func() {
+			if r := recover(); r != nil {
+				if err := store.Release(ctx, userID, key); err != nil {
+					logger.Error("failed to release idempotency key",
+						slog.Int("user_id", userID),
+						slog.String("error", err.Error()),
+					)
+				}
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		// Server errors are not stored so that the client can retry them.
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := store.Release(ctx, userID, key); err != nil {
+				logger.Error("failed to release idempotency key",
+					slog.Int("user_id", userID),
+					slog.String("error", err.Error()),
+				)
+			}
+			return
+		}
+
+		if err := store.Complete(ctx, userID, key, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			logger.Error("failed to save idempotent response",
+				slog.Int("user_id", userID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAPolicyProvider interface {
+	MFARequired(ctx context.Context, role string) (bool, error)
+}
+
+// RequireMFA rejects tokens of sessions opened without a second factor when
+// the user's role must use two-factor authentication. It must run after
+// AuthMiddleware.
+func RequireMFA(provider MFAPolicyProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("mfa") {
+			c.Next()
+			return
+		}
+
+		required, err := provider.MFARequired(c, c.GetString("role"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check two-factor policy"})
+			return
+		}
+
+		if required {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required, enable it at /auth/mfa/enroll"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -2,31 +2,32 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RequireRole(roles ...string) gin.HandlerFunc {
+// RequirePermission lets through only requests whose permissions, set by
+// AuthMiddleware or AuthOrAPIKeyMiddleware, include permission.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roleVal, exists := c.Get("role")
+		permissionsVal, exists := c.Get("permissions")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role not found"})
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permissions not found"})
 			return
 		}
 
-		role, ok := roleVal.(string)
+		permissions, ok := permissionsVal.([]string)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid role"})
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid permissions"})
 			return
 		}
 
-		for _, r := range roles {
-			if role == r {
-				c.Next()
-				return
-			}
+		if !slices.Contains(permissions, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
 		}
 
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		c.Next()
 	}
 }
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationProvider interface {
+	EmailVerified(ctx context.Context, userID int) (bool, error)
+}
+
+// RequireVerifiedEmail rejects users who haven't confirmed their email. It
+// must run after AuthMiddleware.
+func RequireVerifiedEmail(provider EmailVerificationProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verified, err := provider.EmailVerified(c, c.GetInt("userID"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check email verification"})
+			return
+		}
+
+		if !verified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "email is not verified"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestJWKPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	rsaN := encode(rsaKey.N.Bytes())
+	rsaE := encode(big.NewInt(int64(rsaKey.E)).Bytes())
+	ecX := encode(ecKey.X.Bytes())
+	ecY := encode(ecKey.Y.Bytes())
+
+	tests := []struct {
+		name string
+		key  jwk
+		want string
+	}{
+		{"rsa", jwk{Kty: "RSA", N: rsaN, E: rsaE}, "rsa"},
+		{"rsa under 2048 bits", jwk{Kty: "RSA", N: encode(smallRSAKey.N.Bytes()), E: rsaE}, ""},
+		{"rsa without exponent", jwk{Kty: "RSA", N: rsaN}, ""},
+		{"rsa with bad encoding", jwk{Kty: "RSA", N: "!!", E: rsaE}, ""},
+		{"ec p-256", jwk{Kty: "EC", Crv: "P-256", X: ecX, Y: ecY}, "ec"},
+		{"ec on the wrong curve", jwk{Kty: "EC", Crv: "P-384", X: ecX, Y: ecY}, ""},
+		{"ec unknown curve", jwk{Kty: "EC", Crv: "P-521", X: ecX, Y: ecY}, ""},
+		{"ec point off the curve", jwk{Kty: "EC", Crv: "P-256", X: ecX, Y: ecX}, ""},
+		{"ed25519", jwk{Kty: "OKP", Crv: "Ed25519", X: encode(edKey)}, "ed25519"},
+		{"ed25519 wrong size", jwk{Kty: "OKP", Crv: "Ed25519", X: encode(edKey[:16])}, ""},
+		{"okp other curve", jwk{Kty: "OKP", Crv: "X25519", X: encode(edKey)}, ""},
+		{"unknown type", jwk{Kty: "oct", N: rsaN}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			switch key := tt.key.publicKey().(type) {
+			case nil:
+			case *rsa.PublicKey:
+				if key.N.Cmp(rsaKey.N) == 0 && key.E == rsaKey.E {
+					got = "rsa"
+				}
+			case *ecdsa.PublicKey:
+				if key.Equal(&ecKey.PublicKey) {
+					got = "ec"
+				}
+			case ed25519.PublicKey:
+				if key.Equal(edKey) {
+					got = "ed25519"
+				}
+			default:
+				t.Fatalf("unexpected key type %T", key)
+			}
+			if got != tt.want {
+				t.Errorf("publicKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
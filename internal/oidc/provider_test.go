@@ -0,0 +1,55 @@
+package oidc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	tests := []struct {
+		verifier string
+		want     string
+	}{
+		// Base64url without padding of the FIPS 180-2 SHA-256 digests.
+		{"abc", "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0"},
+		{"", "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU"},
+	}
+
+	for _, tt := range tests {
+		if got := CodeChallenge(tt.verifier); got != tt.want {
+			t.Errorf("CodeChallenge(%q) = %s, want %s", tt.verifier, got, tt.want)
+		}
+	}
+}
+
+func TestFlexBoolUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    flexBool
+		wantErr bool
+	}{
+		{`true`, true, false},
+		{`false`, false, false},
+		{`"true"`, true, false},
+		{`"false"`, false, false},
+		{`null`, false, false},
+		{`"yes"`, false, true},
+		{`1`, false, true},
+		{`""`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var claims struct {
+				EmailVerified flexBool `json:"email_verified"`
+			}
+			err := json.Unmarshal([]byte(`{"email_verified":`+tt.input+`}`), &claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && claims.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", claims.EmailVerified, tt.want)
+			}
+		})
+	}
+}
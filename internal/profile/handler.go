@@ -0,0 +1,219 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileServiceInterface interface {
+	GetProfile(ctx context.Context, userID int, role string) (*Profile, *ErrorResponse)
+	UpdateProfile(ctx context.Context, userID int, role string, body *UpdateProfileRequest) (*Profile, *ErrorResponse)
+	SetAvatar(ctx context.Context, userID int, role string, file io.Reader, size int64) (*Profile, *ErrorResponse)
+	DeleteAvatar(ctx context.Context, userID int) (*StatusResponse, *ErrorResponse)
+	OpenAvatar(ctx context.Context, userID int) (io.ReadCloser, string, *ErrorResponse)
+	DeleteAccount(ctx context.Context, userID int, sessionID string, body *DeleteAccountRequest, ip string) (*StatusResponse, *ErrorResponse)
+	Export(ctx context.Context, userID int, role, format string) (*Export, *ErrorResponse)
+	WriteArchive(ctx context.Context, w io.Writer, export *Export) error
+}
+
+type ProfileHandler struct {
+	service ProfileServiceInterface
+}
+
+func NewProfileHandler(service ProfileServiceInterface) *ProfileHandler {
+	return &ProfileHandler{
+		service: service,
+	}
+}
+
+// @Summary      Get my profile
+// @Description  Get the current user's profile. Drivers also get their driver profile
+// @Tags         profile
+// @Produce      json
+// @Success      200  {object}  Profile
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me [get]
+func (ph *ProfileHandler) GetProfile(c *gin.Context) {
+	profile, err := ph.service.GetProfile(c, c.GetInt("userID"), c.GetString("role"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary      Update my profile
+// @Description  Change the fields that are set. An empty phone or language removes it, a new phone has to be verified again. Only drivers can set driver fields
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        body  body      UpdateProfileRequest  true  "Profile fields"
+// @Success      200   {object}  Profile
+// @Failure      400   {object}  ErrorResponse
+// @Failure      403   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me [patch]
+func (ph *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var body UpdateProfileRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	profile, err := ph.service.UpdateProfile(c, c.GetInt("userID"), c.GetString("role"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary      Upload avatar
+// @Description  Upload or replace the current user's avatar. Accepts JPEG, PNG or WebP up to 2 MB
+// @Tags         profile
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file  formData  file  true  "Avatar image"
+// @Success      200   {object}  Profile
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me/avatar [put]
+func (ph *ProfileHandler) SetAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarSize+1<<20)
+
+	fileHeader, formErr := c.FormFile("file")
+	if formErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	file, openErr := fileHeader.Open()
+	if openErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	defer file.Close()
+
+	profile, err := ph.service.SetAvatar(c, c.GetInt("userID"), c.GetString("role"), file, fileHeader.Size)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary      Get avatar
+// @Description  Download the current user's avatar
+// @Tags         profile
+// @Produce      image/jpeg,image/png,image/webp
+// @Success      200  {file}    file
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me/avatar [get]
+func (ph *ProfileHandler) GetAvatar(c *gin.Context) {
+	r, contentType, err := ph.service.OpenAvatar(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, -1, contentType, r, nil)
+}
+
+// @Summary      Delete avatar
+// @Description  Remove the current user's avatar
+// @Tags         profile
+// @Produce      json
+// @Success      200  {object}  StatusResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me/avatar [delete]
+func (ph *ProfileHandler) DeleteAvatar(c *gin.Context) {
+	status, err := ph.service.DeleteAvatar(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Delete my account
+// @Description  Delete the current user's account after confirming the password. Without a password, the session must have been opened with two-factor authentication less than 10 minutes ago. Personal data is anonymized, rides are kept for accounting. Not possible while the user has active rides
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        body  body      DeleteAccountRequest  true  "Password, optional in a fresh two-factor session"
+// @Success      200   {object}  StatusResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me [delete]
+func (ph *ProfileHandler) DeleteAccount(c *gin.Context) {
+	var body DeleteAccountRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	status, err := ph.service.DeleteAccount(c, c.GetInt("userID"), c.GetString("sessionID"), &body, c.ClientIP())
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary      Export my data
+// @Description  Download everything stored about the current user: profile, rides with routes, promo redemptions, driver application, vehicles and sessions. The zip format also contains the uploaded files
+// @Tags         profile
+// @Produce      json,application/zip
+// @Param        format  query     string  false  "Archive format"  Enums(json, zip)  default(json)
+// @Success      200     {object}  ExportSwagger
+// @Failure      400     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /me/export [get]
+func (ph *ProfileHandler) Export(c *gin.Context) {
+	userID := c.GetInt("userID")
+	format := c.DefaultQuery("format", ExportFormatJSON)
+
+	export, err := ph.service.Export(c, userID, c.GetString("role"), format)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+
+	filename := fmt.Sprintf("go-ride-export-%d.%s", userID, format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == ExportFormatJSON {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	// The status is already sent, so a failure can only cut the archive
+	// short; the service logs it.
+	_ = ph.service.WriteArchive(c, c.Writer, export)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/auth"
+	"github.com/AzizovHikmatullo/go-ride/internal/drivers"
+	"github.com/AzizovHikmatullo/go-ride/internal/rides"
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
+	"github.com/lib/pq"
+)
+
+const (
+	maxBioLength     = 500
+	maxLanguageCount = 10
+	maxAvatarSize    = 2 << 20
+	// reauthMaxAge is how recent a two-factor login must be to delete the
+	// account without the password.
+	reauthMaxAge = 10 * time.Minute
+	// avatarURL serves the current user's avatar, see OpenAvatar.
+	avatarURL = "/me/avatar"
+)
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatZIP  = "zip"
+)
+
+var avatarContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// languagePattern matches a language tag such as "ru" or "en-US".
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+var (
+	errUserNotFound    = errors.New("user not found")
+	errAvatarNotFound  = errors.New("avatar not found")
+	errPhoneTaken      = errors.New("user with this phone already exists")
+	errInvalidName     = errors.New("name must be between 2 and 100 characters")
+	errInvalidPhone    = errors.New("phone must be in E.164 format, e.g. +992900123456")
+	errInvalidLanguage = errors.New("language must be a language tag such as ru or en-US")
+	errInvalidBio      = errors.New("bio must not be longer than 500 characters")
+	errTooManyLangs    = errors.New("at most 10 languages can be listed")
+	errNotDriver       = errors.New("driver profile is only available to drivers")
+	errAvatarTooLarge  = errors.New("avatar must not be larger than 2 MB")
+	errAvatarFormat    = errors.New("avatar must be a JPEG, PNG or WebP image")
+	errActiveRides     = errors.New("finish or cancel your active rides before deleting the account")
+	errWrongPassword   = errors.New("wrong password. Accounts created through a provider login can set one with a password reset")
+	errReauthRequired  = errors.New("confirm with your password, or sign in again with two-factor authentication and delete the account within 10 minutes")
+	errExportFormat    = errors.New("format must be json or zip")
+)
+
+// Profile is the current user's view of their account. It is a separate
+// type from auth.User so credentials never end up in a response.
+type Profile struct {
+	ID                int            `json:"id" db:"id"`
+	Name              string         `json:"name" db:"name"`
+	Email             string         `json:"email" db:"email"`
+	EmailVerifiedAt   *time.Time     `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	Phone             *string        `json:"phone,omitempty" db:"phone"`
+	PhoneVerifiedAt   *time.Time     `json:"phone_verified_at,omitempty" db:"phone_verified_at"`
+	Role              string         `json:"role" db:"role"`
+	PreferredLanguage *string        `json:"preferred_language,omitempty" db:"preferred_language"`
+	AvatarURL         *string        `json:"avatar_url,omitempty" db:"-"`
+	AvatarKey         *string        `json:"-" db:"avatar_key"`
+	Driver            *DriverProfile `json:"driver,omitempty" db:"-"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+}
+
+// DriverProfile holds what riders see about a driver besides the vehicle.
+// It is only present for roles that take rides.
+type DriverProfile struct {
+	Bio       string         `json:"bio" db:"bio"`
+	Languages pq.StringArray `json:"languages" db:"languages" swaggertype:"array,string"`
+}
+
+// UpdateProfileRequest changes only the fields that are set. An empty phone
+// removes it.
+type UpdateProfileRequest struct {
+	Name              *string                     `json:"name,omitempty" example:"Ali Valiev"`
+	Phone             *string                     `json:"phone,omitempty" example:"+992900123456"`
+	PreferredLanguage *string                     `json:"preferred_language,omitempty" example:"ru"`
+	Driver            *UpdateDriverProfileRequest `json:"driver,omitempty"`
+}
+
+type UpdateDriverProfileRequest struct {
+	Bio       *string  `json:"bio,omitempty" example:"5 years behind the wheel"`
+	Languages []string `json:"languages,omitempty" example:"ru,tg,en"`
+}
+
+// DeleteAccountRequest confirms the deletion. The password can be left out
+// in a session opened with two-factor authentication less than
+// reauthMaxAge ago.
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"secret123"`
+}
+
+// Export is everything the service stores about a user. Ratings and
+// payments aren't part of it because the service doesn't keep them; fares
+// are in the rides.
+type Export struct {
+	ExportedAt        time.Time            `json:"exported_at"`
+	Profile           *Profile             `json:"profile"`
+	Rides             []rides.Ride         `json:"rides"`
+	DriverRides       []rides.Ride         `json:"driver_rides,omitempty"`
+	PromoRedemptions  []PromoRedemption    `json:"promo_redemptions"`
+	DriverApplication *drivers.Application `json:"driver_application,omitempty"`
+	Vehicles          []vehicles.Vehicle   `json:"vehicles,omitempty"`
+	Sessions          []auth.Session       `json:"sessions"`
+}
+
+type ExportSwagger struct {
+	ExportedAt        time.Time            `json:"exported_at"`
+	Profile           *Profile             `json:"profile"`
+	Rides             []rides.RideSwagger  `json:"rides"`
+	DriverRides       []rides.RideSwagger  `json:"driver_rides,omitempty"`
+	PromoRedemptions  []PromoRedemption    `json:"promo_redemptions"`
+	DriverApplication *drivers.Application `json:"driver_application,omitempty"`
+	Vehicles          []vehicles.Vehicle   `json:"vehicles,omitempty"`
+	Sessions          []auth.Session       `json:"sessions"`
+}
+
+type PromoRedemption struct {
+	ID        int       `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Discount  float64   `json:"discount" db:"discount"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// deletedAccount is what AnonymizeUser leaves behind in the blob store.
+type deletedAccount struct {
+	avatarKey    string
+	documentKeys []string
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
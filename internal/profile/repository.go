@@ -0,0 +1,347 @@
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/auth"
+	"github.com/AzizovHikmatullo/go-ride/internal/drivers"
+	"github.com/AzizovHikmatullo/go-ride/internal/rides"
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	profileColumns      = "id, name, email, email_verified_at, phone, phone_verified_at, role, preferred_language, avatar_key, created_at"
+	uniqueViolationCode = "23505"
+)
+
+// Columns of the other packages' tables read for the data export.
+const (
+	rideColumns        = "id, user_id, driver_id, status, start_point, end_point, route, fare, discount, promo_redemption_id, area_id, tariff_id, vehicle_class, vehicle_id, created_at, updated_at"
+	vehicleColumns     = "id, driver_id, make, model, plate, color, seats, class, is_active, created_at, updated_at"
+	applicationColumns = "id, user_id, status, review_note, reviewed_by, reviewed_at, created_at, updated_at"
+	documentColumns    = "id, application_id, type, storage_key, content_type, size, uploaded_at"
+	sessionColumns     = "id, device_name, user_agent, ip, created_at, last_used_at"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) GetProfile(ctx context.Context, userID int) (*Profile, error) {
+	var profile Profile
+
+	err := pr.db.GetContext(ctx, &profile, "SELECT "+profileColumns+" FROM users WHERE id = $1", userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUserNotFound
+		}
+		pr.logger.Error("failed to get profile",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// GetDriverProfile returns an empty profile for drivers who haven't filled
+// it in yet.
+func (pr *postgresRepo) GetDriverProfile(ctx context.Context, userID int) (*DriverProfile, error) {
+	driver := DriverProfile{Languages: pq.StringArray{}}
+
+	err := pr.db.GetContext(ctx, &driver, "SELECT bio, languages FROM driver_profiles WHERE user_id = $1", userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		pr.logger.Error("failed to get driver profile",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get driver profile: %w", err)
+	}
+
+	return &driver, nil
+}
+
+// UpdateProfile saves the editable fields of profile and, when set, its
+// driver profile. A changed phone loses its verification.
+func (pr *postgresRepo) UpdateProfile(ctx context.Context, profile *Profile) (err error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to begin transaction", slog.String("error", err.Error()))
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET name = $2, phone = $3, preferred_language = $4,
+		phone_verified_at = CASE WHEN phone IS DISTINCT FROM $3 THEN NULL ELSE phone_verified_at END
+		WHERE id = $1`, profile.ID, profile.Name, profile.Phone, profile.PreferredLanguage)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			return errPhoneTaken
+		}
+		pr.logger.Error("failed to update profile",
+			slog.Int("user_id", profile.ID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	if profile.Driver != nil {
+		_, err = tx.ExecContext(ctx, `INSERT INTO driver_profiles (user_id, bio, languages) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO UPDATE SET bio = EXCLUDED.bio, languages = EXCLUDED.languages, updated_at = now()`,
+			profile.ID, profile.Driver.Bio, profile.Driver.Languages)
+		if err != nil {
+			pr.logger.Error("failed to update driver profile",
+				slog.Int("user_id", profile.ID),
+				slog.String("error", err.Error()),
+			)
+			return fmt.Errorf("failed to update profile: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update profile",
+			slog.Int("user_id", profile.ID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return nil
+}
+
+// SetAvatarKey points the user's avatar at key, or removes it when key is
+// nil, and returns the key it replaced.
+func (pr *postgresRepo) SetAvatarKey(ctx context.Context, userID int, key *string) (string, error) {
+	var previous sql.NullString
+
+	err := pr.db.GetContext(ctx, &previous, `UPDATE users u SET avatar_key = $2
+		FROM users old WHERE u.id = $1 AND old.id = u.id RETURNING old.avatar_key`, userID, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errUserNotFound
+		}
+		pr.logger.Error("failed to set avatar",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return "", fmt.Errorf("failed to set avatar: %w", err)
+	}
+
+	return previous.String, nil
+}
+
+// personalTables hold nothing but a user's credentials and settings, so they
+// are emptied when the account is deleted.
+var personalTables = []string{
+	"refresh_tokens", "sessions", "user_mfa", "mfa_recovery_codes", "mfa_challenges",
+	"user_identities", "api_keys", "email_verification_tokens", "password_reset_tokens",
+	"idempotency_keys", "driver_profiles",
+}
+
+func (pr *postgresRepo) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	var hash string
+
+	err := pr.db.GetContext(ctx, &hash, "SELECT password_hash FROM users WHERE id = $1", userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errUserNotFound
+		}
+		pr.logger.Error("failed to get password hash",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// IsFreshMFASession reports whether the user's session was opened with two-factor
+// authentication less than maxAge ago.
+func (pr *postgresRepo) IsFreshMFASession(ctx context.Context, userID int, sessionID string, maxAge time.Duration) (bool, error) {
+	var fresh bool
+
+	err := pr.db.GetContext(ctx, &fresh, "SELECT mfa AND created_at > now() - make_interval(secs => $3) FROM sessions WHERE id = $1 AND user_id = $2", sessionID, userID, maxAge.Seconds())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		pr.logger.Error("failed to get session",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return fresh, nil
+}
+
+// AnonymizeUser replaces the user's personal data with placeholders and
+// deletes their credentials, sessions and driver documents. Rides, promo
+// redemptions and vehicles stay for accounting. The blobs that are no
+// longer referenced are returned for the caller to remove.
+func (pr *postgresRepo) AnonymizeUser(ctx context.Context, userID int) (_ *deletedAccount, err error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to begin transaction", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var avatarKey sql.NullString
+	err = tx.GetContext(ctx, &avatarKey, "SELECT avatar_key FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errUserNotFound
+		}
+		pr.logger.Error("failed to lock user",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	var active bool
+	err = tx.GetContext(ctx, &active, "SELECT EXISTS (SELECT 1 FROM rides WHERE (user_id = $1 OR driver_id = $1) AND status IN ('SEARCHING', 'IN_PROGRESS'))", userID)
+	if err != nil {
+		pr.logger.Error("failed to check active rides",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+	if active {
+		return nil, errActiveRides
+	}
+
+	deleted := &deletedAccount{avatarKey: avatarKey.String}
+	err = tx.SelectContext(ctx, &deleted.documentKeys, `DELETE FROM driver_documents d USING driver_applications a
+		WHERE a.id = d.application_id AND a.user_id = $1 RETURNING d.storage_key`, userID)
+	if err != nil {
+		pr.logger.Error("failed to delete driver documents",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	statements := []string{
+		"DELETE FROM otp_codes WHERE phone = (SELECT phone FROM users WHERE id = $1)",
+		"UPDATE vehicles SET is_active = FALSE, updated_at = now() WHERE driver_id = $1 AND is_active",
+	}
+	for _, table := range personalTables {
+		statements = append(statements, "DELETE FROM "+table+" WHERE user_id = $1")
+	}
+	statements = append(statements, `UPDATE users SET name = 'Deleted user', email = 'deleted-' || id || '@deleted.invalid',
+		password_hash = '', phone = NULL, phone_verified_at = NULL, email_verified_at = NULL,
+		preferred_language = NULL, avatar_key = NULL, deleted_at = now(), token_version = token_version + 1
+		WHERE id = $1`)
+
+	for _, statement := range statements {
+		if _, err = tx.ExecContext(ctx, statement, userID); err != nil {
+			pr.logger.Error("failed to anonymize user",
+				slog.Int("user_id", userID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to delete account: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to delete account",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// GetExport collects the user's data except the profile. Everything is read
+// in one transaction so the parts are consistent with each other.
+func (pr *postgresRepo) GetExport(ctx context.Context, userID int) (_ *Export, err error) {
+	tx, err := pr.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		pr.logger.Error("failed to begin transaction", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to export data: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	export := &Export{
+		Rides:            []rides.Ride{},
+		DriverRides:      []rides.Ride{},
+		PromoRedemptions: []PromoRedemption{},
+		Vehicles:         []vehicles.Vehicle{},
+		Sessions:         []auth.Session{},
+	}
+
+	var application drivers.Application
+	err = tx.GetContext(ctx, &application, "SELECT "+applicationColumns+" FROM driver_applications WHERE user_id = $1", userID)
+	switch {
+	case err == nil:
+		application.Documents = []drivers.Document{}
+		export.DriverApplication = &application
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, pr.exportError(userID, "driver application", err)
+	}
+
+	queries := []struct {
+		part  string
+		dest  interface{}
+		query string
+	}{
+		{"rides", &export.Rides, "SELECT " + rideColumns + " FROM rides WHERE user_id = $1 ORDER BY id"},
+		{"driver rides", &export.DriverRides, "SELECT " + rideColumns + " FROM rides WHERE driver_id = $1 ORDER BY id"},
+		{"promo redemptions", &export.PromoRedemptions, `SELECT r.id, c.code, r.discount, r.created_at FROM promo_redemptions r
+			JOIN promo_codes c ON c.id = r.promo_code_id WHERE r.user_id = $1 ORDER BY r.id`},
+		{"vehicles", &export.Vehicles, "SELECT " + vehicleColumns + " FROM vehicles WHERE driver_id = $1 ORDER BY id"},
+		{"sessions", &export.Sessions, "SELECT " + sessionColumns + " FROM sessions WHERE user_id = $1 ORDER BY created_at"},
+	}
+	for _, q := range queries {
+		if err = tx.SelectContext(ctx, q.dest, q.query, userID); err != nil {
+			return nil, pr.exportError(userID, q.part, err)
+		}
+	}
+
+	if export.DriverApplication != nil {
+		err = tx.SelectContext(ctx, &export.DriverApplication.Documents, "SELECT "+documentColumns+" FROM driver_documents WHERE application_id = $1 ORDER BY type", application.ID)
+		if err != nil {
+			return nil, pr.exportError(userID, "driver documents", err)
+		}
+	}
+
+	return export, nil
+}
+
+func (pr *postgresRepo) exportError(userID int, part string, err error) error {
+	pr.logger.Error("failed to export "+part,
+		slog.Int("user_id", userID),
+		slog.String("error", err.Error()),
+	)
+	return fmt.Errorf("failed to export data: %w", err)
+}
@@ -0,0 +1,430 @@
+package profile
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/audit"
+	"github.com/AzizovHikmatullo/go-ride/internal/auth"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/AzizovHikmatullo/go-ride/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type RepositoryInterface interface {
+	GetProfile(ctx context.Context, userID int) (*Profile, error)
+	GetDriverProfile(ctx context.Context, userID int) (*DriverProfile, error)
+	UpdateProfile(ctx context.Context, profile *Profile) error
+	SetAvatarKey(ctx context.Context, userID int, key *string) (string, error)
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	IsFreshMFASession(ctx context.Context, userID int, sessionID string, maxAge time.Duration) (bool, error)
+	AnonymizeUser(ctx context.Context, userID int) (*deletedAccount, error)
+	GetExport(ctx context.Context, userID int) (*Export, error)
+}
+
+// SessionRevoker ends a user's sessions and revokes their access tokens.
+type SessionRevoker interface {
+	RevokeAllSessions(ctx context.Context, userID int) (*auth.StatusResponse, *auth.ErrorResponse)
+}
+
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *audit.Entry)
+}
+
+type ProfileService struct {
+	repo     RepositoryInterface
+	blobs    storage.BlobStore
+	sessions SessionRevoker
+	audit    AuditRecorder
+	logger   *slog.Logger
+}
+
+func NewProfileService(repository RepositoryInterface, blobs storage.BlobStore, sessions SessionRevoker, audit AuditRecorder, logger *slog.Logger) *ProfileService {
+	return &ProfileService{
+		repo:     repository,
+		blobs:    blobs,
+		sessions: sessions,
+		audit:    audit,
+		logger:   logger,
+	}
+}
+
+// GetProfile returns the user's profile. Roles that take rides also get
+// their driver profile.
+func (ps *ProfileService) GetProfile(ctx context.Context, userID int, role string) (*Profile, *ErrorResponse) {
+	profile, err := ps.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, profileErrorResponse(err)
+	}
+
+	if rbac.Can(role, rbac.RidesTake) {
+		profile.Driver, err = ps.repo.GetDriverProfile(ctx, userID)
+		if err != nil {
+			return nil, NewErrorResponse(err)
+		}
+	}
+
+	if profile.AvatarKey != nil {
+		url := avatarURL
+		profile.AvatarURL = &url
+	}
+
+	return profile, nil
+}
+
+func (ps *ProfileService) UpdateProfile(ctx context.Context, userID int, role string, body *UpdateProfileRequest) (*Profile, *ErrorResponse) {
+	profile, errResp := ps.GetProfile(ctx, userID, role)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		if n := utf8.RuneCountInString(name); n < auth.MinNameLength || n > auth.MaxNameLength {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidName)
+		}
+		profile.Name = name
+	}
+
+	if body.Phone != nil {
+		phone := auth.NormalizePhone(*body.Phone)
+		switch {
+		case phone == "":
+			profile.Phone = nil
+		case auth.ValidPhone(phone):
+			profile.Phone = &phone
+		default:
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidPhone)
+		}
+	}
+
+	if body.PreferredLanguage != nil {
+		language := strings.TrimSpace(*body.PreferredLanguage)
+		switch {
+		case language == "":
+			profile.PreferredLanguage = nil
+		case languagePattern.MatchString(language):
+			profile.PreferredLanguage = &language
+		default:
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidLanguage)
+		}
+	}
+
+	// Only a changed driver profile is written back.
+	driver := profile.Driver
+	profile.Driver = nil
+	if body.Driver != nil {
+		if driver == nil {
+			return nil, NewErrorResponseWithStatus(http.StatusForbidden, errNotDriver)
+		}
+
+		if body.Driver.Bio != nil {
+			bio := strings.TrimSpace(*body.Driver.Bio)
+			if utf8.RuneCountInString(bio) > maxBioLength {
+				return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidBio)
+			}
+			driver.Bio = bio
+		}
+
+		if body.Driver.Languages != nil {
+			languages, errResp := normalizeLanguages(body.Driver.Languages)
+			if errResp != nil {
+				return nil, errResp
+			}
+			driver.Languages = languages
+		}
+
+		profile.Driver = driver
+	}
+
+	if err := ps.repo.UpdateProfile(ctx, profile); err != nil {
+		return nil, profileErrorResponse(err)
+	}
+
+	profile.Driver = driver
+	return profile, nil
+}
+
+func normalizeLanguages(languages []string) ([]string, *ErrorResponse) {
+	if len(languages) > maxLanguageCount {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errTooManyLangs)
+	}
+
+	normalized := make([]string, 0, len(languages))
+	for _, language := range languages {
+		language = strings.TrimSpace(language)
+		if !languagePattern.MatchString(language) {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errInvalidLanguage)
+		}
+		normalized = append(normalized, language)
+	}
+
+	return normalized, nil
+}
+
+// SetAvatar stores an image as the user's avatar, replacing the previous one.
+func (ps *ProfileService) SetAvatar(ctx context.Context, userID int, role string, file io.Reader, size int64) (*Profile, *ErrorResponse) {
+	if size > maxAvatarSize {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errAvatarTooLarge)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("failed to read avatar"))
+	}
+	head = head[:n]
+
+	ext, ok := avatarContentTypes[http.DetectContentType(head)]
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errAvatarFormat)
+	}
+
+	key := fmt.Sprintf("avatars/%d/%d%s", userID, time.Now().UnixNano(), ext)
+	if err := ps.blobs.Put(ctx, key, io.MultiReader(bytes.NewReader(head), file)); err != nil {
+		ps.logger.Error("failed to store avatar",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, NewErrorResponse(fmt.Errorf("failed to store avatar"))
+	}
+
+	previousKey, err := ps.repo.SetAvatarKey(ctx, userID, &key)
+	if err != nil {
+		_ = ps.blobs.Delete(ctx, key)
+		return nil, profileErrorResponse(err)
+	}
+	ps.deleteBlob(ctx, userID, previousKey)
+
+	return ps.GetProfile(ctx, userID, role)
+}
+
+func (ps *ProfileService) DeleteAvatar(ctx context.Context, userID int) (*StatusResponse, *ErrorResponse) {
+	previousKey, err := ps.repo.SetAvatarKey(ctx, userID, nil)
+	if err != nil {
+		return nil, profileErrorResponse(err)
+	}
+	if previousKey == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusNotFound, errAvatarNotFound)
+	}
+	ps.deleteBlob(ctx, userID, previousKey)
+
+	return &StatusResponse{Status: "avatar deleted"}, nil
+}
+
+// OpenAvatar returns the user's avatar and its content type. The caller must
+// close the reader.
+func (ps *ProfileService) OpenAvatar(ctx context.Context, userID int) (io.ReadCloser, string, *ErrorResponse) {
+	profile, err := ps.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, "", profileErrorResponse(err)
+	}
+	if profile.AvatarKey == nil {
+		return nil, "", NewErrorResponseWithStatus(http.StatusNotFound, errAvatarNotFound)
+	}
+
+	r, err := ps.blobs.Get(ctx, *profile.AvatarKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, "", NewErrorResponseWithStatus(http.StatusNotFound, errAvatarNotFound)
+		}
+		return nil, "", NewErrorResponse(err)
+	}
+
+	return r, avatarContentType(*profile.AvatarKey), nil
+}
+
+// DeleteAccount anonymizes the user after checking their password, or
+// without it in a fresh two-factor session: accounts created through a
+// provider login have a password nobody knows. The account can't be used
+// afterwards, but its rides are kept for accounting.
+func (ps *ProfileService) DeleteAccount(ctx context.Context, userID int, sessionID string, body *DeleteAccountRequest, ip string) (*StatusResponse, *ErrorResponse) {
+	if errResp := ps.confirmDeletion(ctx, userID, sessionID, body.Password); errResp != nil {
+		return nil, errResp
+	}
+
+	deleted, err := ps.repo.AnonymizeUser(ctx, userID)
+	if err != nil {
+		return nil, profileErrorResponse(err)
+	}
+
+	// The sessions are already gone; this makes the instance drop the
+	// cached token version too.
+	if _, errResp := ps.sessions.RevokeAllSessions(ctx, userID); errResp != nil {
+		ps.logger.Error("failed to revoke tokens of deleted account",
+			slog.Int("user_id", userID),
+			slog.String("error", errResp.Message),
+		)
+	}
+
+	ps.deleteBlob(ctx, userID, deleted.avatarKey)
+	for _, key := range deleted.documentKeys {
+		ps.deleteBlob(ctx, userID, key)
+	}
+
+	ps.audit.Record(ctx, &audit.Entry{
+		ActorID:    &userID,
+		Action:     "account.deleted",
+		TargetType: "users",
+		TargetID:   &userID,
+		IP:         ip,
+	})
+
+	ps.logger.Info("account deleted", slog.Int("user_id", userID))
+
+	return &StatusResponse{Status: "account deleted"}, nil
+}
+
+func (ps *ProfileService) confirmDeletion(ctx context.Context, userID int, sessionID, password string) *ErrorResponse {
+	if password == "" {
+		if sessionID == "" {
+			return NewErrorResponseWithStatus(http.StatusUnauthorized, errReauthRequired)
+		}
+		fresh, err := ps.repo.IsFreshMFASession(ctx, userID, sessionID, reauthMaxAge)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+		if !fresh {
+			return NewErrorResponseWithStatus(http.StatusUnauthorized, errReauthRequired)
+		}
+		return nil
+	}
+
+	hash, err := ps.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return profileErrorResponse(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return NewErrorResponseWithStatus(http.StatusUnauthorized, errWrongPassword)
+	}
+	return nil
+}
+
+// Export collects everything stored about the user.
+func (ps *ProfileService) Export(ctx context.Context, userID int, role, format string) (*Export, *ErrorResponse) {
+	if format != ExportFormatJSON && format != ExportFormatZIP {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errExportFormat)
+	}
+
+	profile, errResp := ps.GetProfile(ctx, userID, role)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	export, err := ps.repo.GetExport(ctx, userID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	export.Profile = profile
+	export.ExportedAt = time.Now().UTC()
+
+	return export, nil
+}
+
+// WriteArchive writes export as a ZIP archive: export.json with the data and
+// the files the user uploaded.
+func (ps *ProfileService) WriteArchive(ctx context.Context, w io.Writer, export *Export) error {
+	if err := ps.writeArchive(ctx, w, export); err != nil {
+		ps.logger.Error("failed to write data export",
+			slog.Int("user_id", export.Profile.ID),
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	return nil
+}
+
+func (ps *ProfileService) writeArchive(ctx context.Context, w io.Writer, export *Export) error {
+	archive := zip.NewWriter(w)
+
+	data, err := archive.Create("export.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(data)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	if key := export.Profile.AvatarKey; key != nil {
+		if err := ps.addBlob(ctx, archive, "avatar"+path.Ext(*key), *key); err != nil {
+			return err
+		}
+	}
+
+	if application := export.DriverApplication; application != nil {
+		for _, document := range application.Documents {
+			name := "documents/" + strings.ToLower(document.Type) + path.Ext(document.StorageKey)
+			if err := ps.addBlob(ctx, archive, name, document.StorageKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	return archive.Close()
+}
+
+// addBlob copies a stored file into the archive. Files missing from the
+// store are skipped, the export still lists them.
+func (ps *ProfileService) addBlob(ctx context.Context, archive *zip.Writer, name, key string) error {
+	r, err := ps.blobs.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (ps *ProfileService) deleteBlob(ctx context.Context, userID int, key string) {
+	if key == "" {
+		return
+	}
+	if err := ps.blobs.Delete(ctx, key); err != nil {
+		ps.logger.Error("failed to delete stored file",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func avatarContentType(key string) string {
+	ext := path.Ext(key)
+	for contentType, typeExt := range avatarContentTypes {
+		if typeExt == ext {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+func profileErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errPhoneTaken), errors.Is(err, errActiveRides):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
@@ -0,0 +1,53 @@
+package promotions
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromoServiceInterface interface {
+	ApplyPromo(ctx context.Context, userID int, body *ApplyRequest) (*ApplyResponse, *ErrorResponse)
+}
+
+type PromoHandler struct {
+	service PromoServiceInterface
+}
+
+func NewPromoHandler(service PromoServiceInterface) *PromoHandler {
+	return &PromoHandler{
+		service: service,
+	}
+}
+
+// @Summary      Apply promo code
+// @Description  Check a promo code against a fare and preview the discount. The code is consumed when a ride is created with it
+// @Tags         promos
+// @Accept       json
+// @Produce      json
+// @Param        body  body      ApplyRequest  true  "Promo code and fare"
+// @Success      200   {object}  ApplyResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     UserAuth
+// @Router       /promos/apply [post]
+func (ph *PromoHandler) Apply(c *gin.Context) {
+	var body ApplyRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	response, err := ph.service.ApplyPromo(c, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
@@ -0,0 +1,89 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	PercentageDiscount = "PERCENTAGE"
+	FixedDiscount      = "FIXED"
+)
+
+var ErrInvalidPromo = errors.New("invalid promo code")
+
+var (
+	errPromoNotFound    = fmt.Errorf("%w: promo code not found", ErrInvalidPromo)
+	errPromoInactive    = fmt.Errorf("%w: promo code is not active", ErrInvalidPromo)
+	errPromoNotStarted  = fmt.Errorf("%w: promo code is not valid yet", ErrInvalidPromo)
+	errPromoExpired     = fmt.Errorf("%w: promo code has expired", ErrInvalidPromo)
+	errPromoExhausted   = fmt.Errorf("%w: promo code usage limit reached", ErrInvalidPromo)
+	errPromoUserLimit   = fmt.Errorf("%w: you have already used this promo code", ErrInvalidPromo)
+	errPromoMinFare     = fmt.Errorf("%w: fare is below the minimum for this promo code", ErrInvalidPromo)
+	errPromoWrongCity   = fmt.Errorf("%w: promo code is not valid in this city", ErrInvalidPromo)
+	errPromoUnknownKind = fmt.Errorf("%w: unknown discount type", ErrInvalidPromo)
+	errPromoCodeMissing = fmt.Errorf("%w: code is required", ErrInvalidPromo)
+)
+
+type PromoCode struct {
+	ID             int        `json:"id" db:"id"`
+	Code           string     `json:"code" db:"code"`
+	DiscountType   string     `json:"discount_type" db:"discount_type"`
+	DiscountValue  float64    `json:"discount_value" db:"discount_value"`
+	MaxUses        *int       `json:"max_uses,omitempty" db:"max_uses"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user,omitempty" db:"max_uses_per_user"`
+	UsedCount      int        `json:"used_count" db:"used_count"`
+	MinFare        float64    `json:"min_fare" db:"min_fare"`
+	City           *string    `json:"city,omitempty" db:"city"`
+	ValidFrom      time.Time  `json:"valid_from" db:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty" db:"valid_until"`
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+type Redemption struct {
+	ID          int     `json:"id" db:"id"`
+	PromoCodeID int     `json:"promo_code_id" db:"promo_code_id"`
+	UserID      int     `json:"user_id" db:"user_id"`
+	Discount    float64 `json:"discount" db:"discount"`
+}
+
+type ApplyRequest struct {
+	Code string  `json:"code"`
+	Fare float64 `json:"fare"`
+	City string  `json:"city,omitempty"`
+}
+
+type ApplyResponse struct {
+	Code          string  `json:"code"`
+	DiscountType  string  `json:"discount_type"`
+	DiscountValue float64 `json:"discount_value"`
+	Discount      float64 `json:"discount"`
+	FinalFare     float64 `json:"final_fare"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
@@ -0,0 +1,137 @@
+package promotions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const promoColumns = "id, code, discount_type, discount_value, max_uses, max_uses_per_user, used_count, min_fare, city, valid_from, valid_until, is_active, created_at"
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) GetPromoByCode(ctx context.Context, code string) (*PromoCode, error) {
+	var promo PromoCode
+
+	err := pr.db.GetContext(ctx, &promo, "SELECT "+promoColumns+" FROM promo_codes WHERE code = $1", code)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errPromoNotFound
+		}
+		pr.logger.Error("failed to get promo code",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+
+	return &promo, nil
+}
+
+func (pr *postgresRepo) CountUserRedemptions(ctx context.Context, promoID, userID int) (int, error) {
+	var count int
+
+	err := pr.db.GetContext(ctx, &count, "SELECT count(*) FROM promo_redemptions WHERE promo_code_id = $1 AND user_id = $2", promoID, userID)
+	if err != nil {
+		pr.logger.Error("failed to count promo redemptions",
+			slog.Int("promo_code_id", promoID),
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return 0, fmt.Errorf("failed to count promo redemptions: %w", err)
+	}
+
+	return count, nil
+}
+
+// RedeemPromo runs in the caller's transaction, so the redemption is only
+// kept if whatever it was redeemed for is created as well. It locks the promo
+// code row until the transaction ends, so concurrent redemptions of the same
+// code are serialized and the usage limits checked by check always see the
+// latest counters.
+func (pr *postgresRepo) RedeemPromo(ctx context.Context, tx *sqlx.Tx, code string, userID int, check func(promo *PromoCode, userRedemptions int) (float64, error)) (*Redemption, error) {
+	var promo PromoCode
+	err := tx.GetContext(ctx, &promo, "SELECT "+promoColumns+" FROM promo_codes WHERE code = $1 FOR UPDATE", code)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errPromoNotFound
+		}
+		pr.logger.Error("failed to lock promo code",
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to redeem promo code: %w", err)
+	}
+
+	var userRedemptions int
+	err = tx.GetContext(ctx, &userRedemptions, "SELECT count(*) FROM promo_redemptions WHERE promo_code_id = $1 AND user_id = $2", promo.ID, userID)
+	if err != nil {
+		pr.logger.Error("failed to count promo redemptions",
+			slog.Int("promo_code_id", promo.ID),
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to redeem promo code: %w", err)
+	}
+
+	discount, err := check(&promo, userRedemptions)
+	if err != nil {
+		return nil, err
+	}
+
+	redemption := &Redemption{PromoCodeID: promo.ID, UserID: userID, Discount: discount}
+	err = tx.QueryRowContext(ctx, "INSERT INTO promo_redemptions (promo_code_id, user_id, discount) VALUES ($1, $2, $3) RETURNING id", promo.ID, userID, discount).Scan(&redemption.ID)
+	if err != nil {
+		pr.logger.Error("failed to create promo redemption",
+			slog.Int("promo_code_id", promo.ID),
+			slog.Int("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to redeem promo code: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE promo_codes SET used_count = used_count + 1 WHERE id = $1", promo.ID)
+	if err != nil {
+		pr.logger.Error("failed to update promo usage",
+			slog.Int("promo_code_id", promo.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to redeem promo code: %w", err)
+	}
+
+	return redemption, nil
+}
+
+// ReleaseRedemption runs in the caller's transaction. The caller has to drop
+// its own references to the redemption first.
+func (pr *postgresRepo) ReleaseRedemption(ctx context.Context, tx *sqlx.Tx, redemptionID int) error {
+	var promoID int
+	err := tx.QueryRowContext(ctx, "DELETE FROM promo_redemptions WHERE id = $1 RETURNING promo_code_id", redemptionID).Scan(&promoID)
+	if err != nil {
+		pr.logger.Error("failed to delete promo redemption",
+			slog.Int("redemption_id", redemptionID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to release promo redemption: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE promo_codes SET used_count = used_count - 1 WHERE id = $1 AND used_count > 0", promoID)
+	if err != nil {
+		pr.logger.Error("failed to update promo usage",
+			slog.Int("promo_code_id", promoID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to release promo redemption: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package promotions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type RepositoryInterface interface {
+	GetPromoByCode(ctx context.Context, code string) (*PromoCode, error)
+	CountUserRedemptions(ctx context.Context, promoID, userID int) (int, error)
+	RedeemPromo(ctx context.Context, tx *sqlx.Tx, code string, userID int, check func(promo *PromoCode, userRedemptions int) (float64, error)) (*Redemption, error)
+	ReleaseRedemption(ctx context.Context, tx *sqlx.Tx, redemptionID int) error
+}
+
+type PromoService struct {
+	repo   RepositoryInterface
+	logger *slog.Logger
+}
+
+func NewPromoService(repository RepositoryInterface, logger *slog.Logger) *PromoService {
+	return &PromoService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+// ApplyPromo previews the discount a code would give on the given fare
+// without consuming it. The code is redeemed when the ride is created.
+func (ps *PromoService) ApplyPromo(ctx context.Context, userID int, body *ApplyRequest) (*ApplyResponse, *ErrorResponse) {
+	code := normalizeCode(body.Code)
+	if code == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, errPromoCodeMissing)
+	}
+
+	promo, err := ps.repo.GetPromoByCode(ctx, code)
+	if err != nil {
+		return nil, promoErrorResponse(err)
+	}
+
+	userRedemptions, err := ps.repo.CountUserRedemptions(ctx, promo.ID, userID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+
+	discount, err := checkPromo(promo, userRedemptions, body.Fare, body.City, time.Now())
+	if err != nil {
+		return nil, promoErrorResponse(err)
+	}
+
+	return &ApplyResponse{
+		Code:          promo.Code,
+		DiscountType:  promo.DiscountType,
+		DiscountValue: promo.DiscountValue,
+		Discount:      discount,
+		FinalFare:     roundMoney(body.Fare - discount),
+	}, nil
+}
+
+// Redeem consumes one use of the code for the user and returns the discount
+// applied to the fare. It runs in tx, so the use is only consumed if the
+// caller commits. Errors wrapping ErrInvalidPromo are caused by the code
+// itself rather than by the storage.
+func (ps *PromoService) Redeem(ctx context.Context, tx *sqlx.Tx, code string, userID int, fare float64, city string) (*Redemption, error) {
+	code = normalizeCode(code)
+	if code == "" {
+		return nil, errPromoCodeMissing
+	}
+
+	redemption, err := ps.repo.RedeemPromo(ctx, tx, code, userID, func(promo *PromoCode, userRedemptions int) (float64, error) {
+		return checkPromo(promo, userRedemptions, fare, city, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ps.logger.Info("promo code redeemed",
+		slog.Int("promo_code_id", redemption.PromoCodeID),
+		slog.Int("user_id", userID),
+		slog.Int("redemption_id", redemption.ID),
+	)
+
+	return redemption, nil
+}
+
+// Release gives back a use of the code when the ride it was redeemed for is
+// canceled before a driver took it. It runs in tx like Redeem.
+func (ps *PromoService) Release(ctx context.Context, tx *sqlx.Tx, redemptionID int) error {
+	err := ps.repo.ReleaseRedemption(ctx, tx, redemptionID)
+	if err != nil {
+		return err
+	}
+
+	ps.logger.Info("promo redemption released",
+		slog.Int("redemption_id", redemptionID),
+	)
+
+	return nil
+}
+
+func checkPromo(promo *PromoCode, userRedemptions int, fare float64, city string, now time.Time) (float64, error) {
+	if !promo.IsActive {
+		return 0, errPromoInactive
+	}
+
+	if now.Before(promo.ValidFrom) {
+		return 0, errPromoNotStarted
+	}
+
+	if promo.ValidUntil != nil && !now.Before(*promo.ValidUntil) {
+		return 0, errPromoExpired
+	}
+
+	if promo.MaxUses != nil && promo.UsedCount >= *promo.MaxUses {
+		return 0, errPromoExhausted
+	}
+
+	if promo.MaxUsesPerUser != nil && userRedemptions >= *promo.MaxUsesPerUser {
+		return 0, errPromoUserLimit
+	}
+
+	if fare < promo.MinFare {
+		return 0, errPromoMinFare
+	}
+
+	if promo.City != nil && !strings.EqualFold(*promo.City, city) {
+		return 0, errPromoWrongCity
+	}
+
+	return calculateDiscount(promo, fare)
+}
+
+func calculateDiscount(promo *PromoCode, fare float64) (float64, error) {
+	var discount float64
+
+	switch promo.DiscountType {
+	case PercentageDiscount:
+		discount = fare * promo.DiscountValue / 100
+	case FixedDiscount:
+		discount = promo.DiscountValue
+	default:
+		return 0, errPromoUnknownKind
+	}
+
+	return roundMoney(math.Min(discount, fare)), nil
+}
+
+func promoErrorResponse(err error) *ErrorResponse {
+	if errors.Is(err, ErrInvalidPromo) {
+		return NewErrorResponseWithStatus(http.StatusBadRequest, err)
+	}
+	return NewErrorResponse(err)
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
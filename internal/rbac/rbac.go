@@ -0,0 +1,102 @@
+// Package rbac maps roles to the permissions routes are guarded by. Tokens
+// carry only the role, so permissions always follow the registry below and a
+// change to it applies to every session at once.
+//
+// Adding a role means adding it to registry; handlers and middleware only
+// ever check permissions.
+package rbac
+
+import "slices"
+
+const (
+	RoleUser       = "USER"
+	RoleDriver     = "DRIVER"
+	RoleAdmin      = "ADMIN"
+	RoleSupport    = "SUPPORT"
+	RoleDispatcher = "DISPATCHER"
+)
+
+const (
+	RidesCreate   = "rides:create"
+	RidesRead     = "rides:read"
+	RidesCancel   = "rides:cancel"
+	RidesSearch   = "rides:search"
+	RidesTake     = "rides:take"
+	RidesComplete = "rides:complete"
+	// RidesReadAny and RidesManage cover every ride, not only the caller's.
+	RidesReadAny = "rides:read_any"
+	RidesManage  = "rides:manage"
+
+	PromosApply    = "promos:apply"
+	VehiclesManage = "vehicles:manage"
+
+	DriverApplicationsSubmit = "driver_applications:submit"
+	DriverApplicationsReview = "driver_applications:review"
+
+	UsersRead     = "users:read"
+	UsersManage   = "users:manage"
+	MFAPolicy     = "mfa_policy:manage"
+	APIKeysManage = "api_keys:manage"
+	AreasManage   = "areas:manage"
+	TariffsManage = "tariffs:manage"
+	AuditRead     = "audit:read"
+)
+
+// All lists every permission. The admin role is granted all of them.
+var All = []string{
+	RidesCreate, RidesRead, RidesCancel, RidesSearch, RidesTake, RidesComplete, RidesReadAny, RidesManage,
+	PromosApply, VehiclesManage,
+	DriverApplicationsSubmit, DriverApplicationsReview,
+	UsersRead, UsersManage, MFAPolicy, APIKeysManage, AreasManage, TariffsManage, AuditRead,
+}
+
+var registry = map[string][]string{
+	RoleUser:   {RidesCreate, RidesRead, RidesCancel, PromosApply, DriverApplicationsSubmit},
+	RoleDriver: {RidesSearch, RidesTake, RidesComplete, VehiclesManage, DriverApplicationsSubmit},
+	RoleAdmin:  All,
+	RoleSupport: {
+		RidesReadAny, UsersRead, DriverApplicationsReview, AuditRead,
+	},
+	RoleDispatcher: {
+		RidesReadAny, RidesManage, AreasManage, TariffsManage,
+	},
+}
+
+// Roles returns the known roles in a stable order.
+func Roles() []string {
+	roles := make([]string, 0, len(registry))
+	for role := range registry {
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+// IsRole reports whether role is in the registry.
+func IsRole(role string) bool {
+	_, ok := registry[role]
+	return ok
+}
+
+// Permissions returns the permissions of role, or nil for an unknown role.
+// The result must not be modified.
+func Permissions(role string) []string {
+	return registry[role]
+}
+
+// Can reports whether role has permission.
+func Can(role, permission string) bool {
+	return slices.Contains(registry[role], permission)
+}
+
+// Intersect returns the permissions present in both a and b. API keys use it
+// so a key can never do more than its user.
+func Intersect(a, b []string) []string {
+	result := make([]string, 0, len(a))
+	for _, permission := range a {
+		if slices.Contains(b, permission) {
+			result = append(result, permission)
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{RoleUser, RidesCreate, true},
+		{RoleUser, RidesTake, false},
+		{RoleUser, RidesReadAny, false},
+		{RoleDriver, RidesTake, true},
+		{RoleDriver, RidesCreate, false},
+		{RoleAdmin, AuditRead, true},
+		{RoleAdmin, APIKeysManage, true},
+		{RoleSupport, AuditRead, true},
+		{RoleSupport, RidesManage, false},
+		{RoleDispatcher, RidesManage, true},
+		{RoleDispatcher, UsersManage, false},
+		{"UNKNOWN", RidesRead, false},
+		{RoleUser, "rides:unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := Can(tt.role, tt.permission); got != tt.want {
+			t.Errorf("Can(%s, %s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestAdminHasEveryPermission(t *testing.T) {
+	for _, permission := range All {
+		if !Can(RoleAdmin, permission) {
+			t.Errorf("admin lacks %s", permission)
+		}
+	}
+
+	for _, role := range Roles() {
+		for _, permission := range Permissions(role) {
+			if !slices.Contains(All, permission) {
+				t.Errorf("%s has %s, which is missing from All", role, permission)
+			}
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []string
+	}{
+		{"overlap keeps the order of a", []string{RidesCancel, RidesCreate, RidesRead}, []string{RidesRead, RidesCreate}, []string{RidesCreate, RidesRead}},
+		{"no overlap", []string{RidesCreate}, []string{RidesTake}, []string{}},
+		{"empty a", nil, []string{RidesCreate}, []string{}},
+		{"empty b", []string{RidesCreate}, nil, []string{}},
+		{"scopes beyond the role are dropped", []string{RidesCreate, RidesTake}, Permissions(RoleUser), []string{RidesCreate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Intersect(tt.a, tt.b)
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("Intersect(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -8,20 +8,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	userRole   = "USER"
-	driverRole = "DRIVER"
-)
-
 type RideServiceInterface interface {
-	CreateRide(ctx context.Context, userID int, start, end PointGeoJSON) (*CreateResponse, *ErrorResponse)
+	CreateRide(ctx context.Context, userID int, body *CreateRequest) (*CreateResponse, *ErrorResponse)
 	GetRideByID(ctx context.Context, rideID int) (*Ride, *ErrorResponse)
 	GetRideStatus(ctx context.Context, rideID int) (string, *ErrorResponse)
 	TakeRide(ctx context.Context, rideID int, driverID int) (*ChangeRideResponse, *ErrorResponse)
 	CompleteRide(ctx context.Context, rideID int) (*ChangeRideResponse, *ErrorResponse)
 	CancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, *ErrorResponse)
-	GetSearchingRides(ctx context.Context) (*SearchRidesResponse, *ErrorResponse)
+	GetSearchingRides(ctx context.Context, driverID int) (*SearchRidesResponse, *ErrorResponse)
 	CheckAccess(rideID, userID int, role string) error
+	ListRides(ctx context.Context, filter *RidesFilter) (*SearchRidesResponse, *ErrorResponse)
+	ForceCancelRide(ctx context.Context, rideID, adminID int) (*ChangeRideResponse, *ErrorResponse)
+	ReassignRide(ctx context.Context, rideID, adminID int, body *ReassignRequest) (*ChangeRideResponse, *ErrorResponse)
 }
 
 type RideHandler struct {
@@ -39,11 +37,13 @@ func NewRideHandler(service RideServiceInterface) *RideHandler {
 // @Tags         rides
 // @Accept       json
 // @Produce      json
-// @Param        body  body      CreateRequest  true  "Ride start/end points"
+// @Param        body  body      CreateRequest  true  "Ride start/end points and optional promo code"
+// @Param        Idempotency-Key  header  string  false  "Key to safely retry the request"
 // @Success      200   {object}  CreateResponseSwagger
 // @Failure      400   {object}  ErrorResponse
 // @Failure      500   {object}  ErrorResponse
 // @Security     UserAuth
+// @Security     APIKeyAuth
 // @Router       /rides [post]
 func (rh *RideHandler) CreateRide(c *gin.Context) {
 	var body CreateRequest
@@ -53,9 +53,9 @@ func (rh *RideHandler) CreateRide(c *gin.Context) {
 		return
 	}
 
-	response, err := rh.service.CreateRide(c, c.GetInt("userID"), body.Start, body.End)
+	response, err := rh.service.CreateRide(c, c.GetInt("userID"), &body)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Message)
+		c.AbortWithStatusJSON(err.StatusCode(http.StatusInternalServerError), err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -72,6 +72,7 @@ func (rh *RideHandler) CreateRide(c *gin.Context) {
 // @Failure      403  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Security     UserAuth
+// @Security     APIKeyAuth
 // @Router       /rides/{id} [get]
 func (rh *RideHandler) GetRideByID(c *gin.Context) {
 	id, ok := c.Params.Get("id")
@@ -86,7 +87,7 @@ func (rh *RideHandler) GetRideByID(c *gin.Context) {
 		return
 	}
 
-	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), userRole)
+	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), c.GetString("role"))
 	if errResp != nil {
 		newErrorResponse(c, http.StatusForbidden, errResp.Error())
 		return
@@ -110,6 +111,7 @@ func (rh *RideHandler) GetRideByID(c *gin.Context) {
 // @Failure      403  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Security     UserAuth
+// @Security     APIKeyAuth
 // @Router       /rides/{id}/status [get]
 func (rh *RideHandler) GetRideStatus(c *gin.Context) {
 	id, ok := c.Params.Get("id")
@@ -124,7 +126,7 @@ func (rh *RideHandler) GetRideStatus(c *gin.Context) {
 		return
 	}
 
-	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), userRole)
+	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), c.GetString("role"))
 	if errResp != nil {
 		newErrorResponse(c, http.StatusForbidden, errResp.Error())
 		return
@@ -151,6 +153,7 @@ func (rh *RideHandler) GetRideStatus(c *gin.Context) {
 // @Failure      403  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Security     UserAuth
+// @Security     APIKeyAuth
 // @Router       /rides/{id}/cancel [post]
 func (rh *RideHandler) CancelRide(c *gin.Context) {
 	id, ok := c.Params.Get("id")
@@ -165,7 +168,7 @@ func (rh *RideHandler) CancelRide(c *gin.Context) {
 		return
 	}
 
-	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), userRole)
+	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), c.GetString("role"))
 	if errResp != nil {
 		newErrorResponse(c, http.StatusForbidden, errResp.Error())
 		return
@@ -180,12 +183,15 @@ func (rh *RideHandler) CancelRide(c *gin.Context) {
 }
 
 // @Summary      Take a ride
-// @Description  Driver takes a ride
+// @Description  Driver takes a ride. The ride's vehicle class must match the driver's active vehicle
 // @Tags         rides
 // @Produce      json
 // @Param        id   path      int  true  "Ride ID"
 // @Success      200  {object}  ChangeRideResponse
 // @Failure      400  {object}  ErrorResponse
+// @Failure      403  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Security     DriverAuth
 // @Router       /rides/{id}/take [post]
@@ -204,7 +210,7 @@ func (rh *RideHandler) TakeRide(c *gin.Context) {
 
 	response, err := rh.service.TakeRide(c, idInt, c.GetInt("userID"))
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Message)
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -234,7 +240,7 @@ func (rh *RideHandler) CompleteRide(c *gin.Context) {
 		return
 	}
 
-	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), driverRole)
+	errResp := rh.service.CheckAccess(rideID, c.GetInt("userID"), c.GetString("role"))
 	if errResp != nil {
 		newErrorResponse(c, http.StatusForbidden, errResp.Error())
 		return
@@ -249,22 +255,117 @@ func (rh *RideHandler) CompleteRide(c *gin.Context) {
 }
 
 // @Summary      Get searching rides
-// @Description  Get all rides with status "searching"
+// @Description  Get all rides with status "searching" that match the class of the driver's active vehicle
 // @Tags         rides
 // @Produce      json
 // @Success      200  {object}  SearchRidesResponseSwagger
+// @Failure      403  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Security     DriverAuth
 // @Router       /rides/search [get]
 func (rh *RideHandler) GetSearchingRides(c *gin.Context) {
-	rides, err := rh.service.GetSearchingRides(c)
+	rides, err := rh.service.GetSearchingRides(c, c.GetInt("userID"))
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Message)
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
 		return
 	}
 	c.JSON(http.StatusOK, rides)
 }
 
+// @Summary      List rides
+// @Description  Search all rides, newest first
+// @Tags         admin
+// @Produce      json
+// @Param        status     query     string  false  "Ride status"  Enums(SEARCHING, IN_PROGRESS, COMPLETED, CANCELED)
+// @Param        user_id    query     int     false  "Rider ID"
+// @Param        driver_id  query     int     false  "Driver ID"
+// @Param        area_id    query     int     false  "Service area ID"
+// @Param        limit      query     int     false  "Page size, 50 by default and 200 at most"
+// @Param        offset     query     int     false  "Number of rides to skip"
+// @Success      200        {object}  SearchRidesResponseSwagger
+// @Failure      400        {object}  ErrorResponse
+// @Failure      500        {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/rides [get]
+func (rh *RideHandler) ListRides(c *gin.Context) {
+	var filter RidesFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	rides, err := rh.service.ListRides(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, rides)
+}
+
+// @Summary      Force cancel a ride
+// @Description  Cancel a ride that is searching for a driver or in progress
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Ride ID"
+// @Success      200  {object}  ChangeRideResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/rides/{id}/cancel [post]
+func (rh *RideHandler) ForceCancelRide(c *gin.Context) {
+	rideID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid ride ID")
+		return
+	}
+
+	response, err := rh.service.ForceCancelRide(c, rideID, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary      Reassign a ride
+// @Description  Give a ride that hasn't finished to another approved driver. The driver's active vehicle must match the ride's class
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int              true  "Ride ID"
+// @Param        body  body      ReassignRequest  true  "New driver"
+// @Success      200   {object}  ChangeRideResponse
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/rides/{id}/reassign [post]
+func (rh *RideHandler) ReassignRide(c *gin.Context) {
+	rideID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid ride ID")
+		return
+	}
+
+	var body ReassignRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil || body.DriverID <= 0 {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	response, err := rh.service.ReassignRide(c, rideID, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 func newErrorResponse(c *gin.Context, statusCode int, message string) {
-	c.AbortWithStatusJSON(statusCode, ErrorResponse{message})
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
 }
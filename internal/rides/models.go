@@ -2,19 +2,34 @@ package rides
 
 import (
 	"encoding/json"
+	"net/http"
 	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/tariffs"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
 )
 
 type Ride struct {
-	ID        int             `json:"id" db:"id"`
-	UserID    int             `json:"user_id" db:"user_id"`
-	DriverID  *int            `json:"driver_id,omitempty" db:"driver_id"`
-	Status    string          `json:"status" db:"status"`
-	Start     json.RawMessage `json:"start_point" db:"start_point"`
-	End       json.RawMessage `json:"end_point" db:"end_point"`
-	Route     json.RawMessage `json:"route" db:"route"`
-	CreatedAt time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at,omitempty" db:"updated_at"`
+	ID                int             `json:"id" db:"id"`
+	UserID            int             `json:"user_id" db:"user_id"`
+	DriverID          *int            `json:"driver_id,omitempty" db:"driver_id"`
+	Status            string          `json:"status" db:"status"`
+	Start             json.RawMessage `json:"start_point" db:"start_point"`
+	End               json.RawMessage `json:"end_point" db:"end_point"`
+	Route             json.RawMessage `json:"route" db:"route"`
+	Fare              *float64        `json:"fare,omitempty" db:"fare"`
+	Discount          float64         `json:"discount" db:"discount"`
+	PromoRedemptionID *int            `json:"promo_redemption_id,omitempty" db:"promo_redemption_id"`
+	AreaID            *int            `json:"area_id,omitempty" db:"area_id"`
+	TariffID          *int            `json:"tariff_id,omitempty" db:"tariff_id"`
+	VehicleClass      string          `json:"vehicle_class" db:"vehicle_class"`
+	VehicleID         *int            `json:"vehicle_id,omitempty" db:"vehicle_id"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at,omitempty" db:"updated_at"`
 }
 
 type APIResponse struct {
@@ -24,6 +39,8 @@ type APIResponse struct {
 
 type Route struct {
 	Geometry Geometry `json:"geometry"`
+	Distance float64  `json:"distance"`
+	Duration float64  `json:"duration"`
 }
 
 type Geometry struct {
@@ -37,14 +54,38 @@ type PointGeoJSON struct {
 }
 
 type CreateRequest struct {
-	Start PointGeoJSON `json:"start_point"`
-	End   PointGeoJSON `json:"end_point"`
+	Start        PointGeoJSON `json:"start_point"`
+	End          PointGeoJSON `json:"end_point"`
+	VehicleClass string       `json:"vehicle_class,omitempty"`
+	PromoCode    string       `json:"promo_code,omitempty"`
+}
+
+type CreateRideParams struct {
+	UserID            int
+	Start             []byte
+	End               []byte
+	Route             json.RawMessage
+	Fare              float64
+	Discount          float64
+	PromoRedemptionID *int
+	AreaID            int
+	TariffID          *int
+	VehicleClass      string
 }
 
 type CreateResponse struct {
-	ID     int             `json:"id" db:"id"`
-	Status string          `json:"status" db:"status"`
-	Route  json.RawMessage `json:"route" db:"route"`
+	ID       int             `json:"id" db:"id"`
+	Status   string          `json:"status" db:"status"`
+	Route    json.RawMessage `json:"route" db:"route"`
+	Fare     float64         `json:"fare" db:"fare"`
+	Discount float64         `json:"discount" db:"discount"`
+}
+
+// Config holds the settings RideService needs from the application config.
+// DefaultRates price rides in areas that have no tariff configured.
+type Config struct {
+	DefaultRates      tariffs.Rates
+	MaxTripDistanceKm float64
 }
 
 type ChangeRideResponse struct {
@@ -56,33 +97,68 @@ type SearchRidesResponse struct {
 	Rides []Ride `json:"rides" db:"ride"`
 }
 
+type RidesFilter struct {
+	Status   string `form:"status"`
+	UserID   int    `form:"user_id"`
+	DriverID int    `form:"driver_id"`
+	AreaID   int    `form:"area_id"`
+	Limit    int    `form:"limit"`
+	Offset   int    `form:"offset"`
+}
+
+type ReassignRequest struct {
+	DriverID int `json:"driver_id"`
+}
+
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	status  int
 }
 
 // Only  for Swagger
 type CreateResponseSwagger struct {
-	ID     int                    `json:"id"`
-	Status string                 `json:"status"`
-	Route  map[string]interface{} `json:"route"`
+	ID       int                    `json:"id"`
+	Status   string                 `json:"status"`
+	Route    map[string]interface{} `json:"route"`
+	Fare     float64                `json:"fare"`
+	Discount float64                `json:"discount"`
 }
 
 type RideSwagger struct {
-	ID        int                    `json:"id" db:"id"`
-	UserID    int                    `json:"user_id" db:"user_id"`
-	DriverID  *int                   `json:"driver_id,omitempty" db:"driver_id"`
-	Status    string                 `json:"status" db:"status"`
-	Start     map[string]interface{} `json:"start_point" db:"start_point"`
-	End       map[string]interface{} `json:"end_point" db:"end_point"`
-	Route     map[string]interface{} `json:"route" db:"route"`
-	CreatedAt time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at,omitempty" db:"updated_at"`
+	ID           int                    `json:"id" db:"id"`
+	UserID       int                    `json:"user_id" db:"user_id"`
+	DriverID     *int                   `json:"driver_id,omitempty" db:"driver_id"`
+	Status       string                 `json:"status" db:"status"`
+	Start        map[string]interface{} `json:"start_point" db:"start_point"`
+	End          map[string]interface{} `json:"end_point" db:"end_point"`
+	Route        map[string]interface{} `json:"route" db:"route"`
+	Fare         *float64               `json:"fare,omitempty" db:"fare"`
+	Discount     float64                `json:"discount" db:"discount"`
+	AreaID       *int                   `json:"area_id,omitempty" db:"area_id"`
+	TariffID     *int                   `json:"tariff_id,omitempty" db:"tariff_id"`
+	VehicleClass string                 `json:"vehicle_class" db:"vehicle_class"`
+	VehicleID    *int                   `json:"vehicle_id,omitempty" db:"vehicle_id"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at,omitempty" db:"updated_at"`
 }
 
 type SearchRidesResponseSwagger struct {
 	Rides []RideSwagger `json:"rides" db:"ride"`
 }
 
+func (f *RidesFilter) normalize() {
+	if f.Limit <= 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+}
+
 func NewChangeRideResponse(id int, status string) *ChangeRideResponse {
 	return &ChangeRideResponse{
 		ID:     id,
@@ -95,3 +171,25 @@ func NewErrorResponse(err error) *ErrorResponse {
 		Message: err.Error(),
 	}
 }
+
+func NewValidationErrorResponse(fields []FieldError) *ErrorResponse {
+	return &ErrorResponse{
+		Message: "validation failed",
+		Fields:  fields,
+		status:  http.StatusBadRequest,
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
@@ -3,11 +3,14 @@ package rides
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 
+	"github.com/AzizovHikmatullo/go-ride/internal/promotions"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 const (
@@ -17,6 +20,23 @@ const (
 	canceledStatus   = "CANCELED"
 )
 
+var (
+	errRideNotFound      = errors.New("ride with this id not found")
+	errRideNotAvailable  = errors.New("ride already taken")
+	errClassMismatch     = errors.New("ride requires a vehicle of another class")
+	errRideFinished      = errors.New("ride is already finished")
+	errDriverNotApproved = errors.New("driver is not approved")
+)
+
+// RedeemFunc redeems a promo code inside the transaction that creates a ride.
+type RedeemFunc func(tx *sqlx.Tx) (*promotions.Redemption, error)
+
+// ReleaseFunc releases a promo redemption inside the transaction that cancels
+// a ride.
+type ReleaseFunc func(tx *sqlx.Tx, redemptionID int) error
+
+const rideColumns = "id, user_id, driver_id, status, start_point, end_point, route, fare, discount, promo_redemption_id, area_id, tariff_id, vehicle_class, vehicle_id, created_at, updated_at"
+
 type postgresRepo struct {
 	db     *sqlx.DB
 	logger *slog.Logger
@@ -26,32 +46,75 @@ func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
 	return &postgresRepo{db, logger}
 }
 
-func (pr *postgresRepo) CreateRide(ctx context.Context, userID int, start, end []byte, route json.RawMessage) (*CreateResponse, error) {
+// CreateRide inserts the ride in the same transaction redeem runs in, so a
+// promo code is never used up by a ride that doesn't exist. redeem may be nil.
+func (pr *postgresRepo) CreateRide(ctx context.Context, params *CreateRideParams, redeem RedeemFunc) (*CreateResponse, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to create ride",
+			slog.Int("user_id", params.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create ride: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if redeem != nil {
+		var redemption *promotions.Redemption
+		redemption, err = redeem(tx)
+		if err != nil {
+			return nil, err
+		}
+		params.Discount = redemption.Discount
+		params.PromoRedemptionID = &redemption.ID
+	}
+
 	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO rides (user_id, status, start_point, end_point, route, fare, discount, promo_redemption_id, area_id, tariff_id, vehicle_class) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
+		params.UserID, searchingStatus, params.Start, params.End, params.Route, params.Fare, params.Discount, params.PromoRedemptionID, params.AreaID, params.TariffID, params.VehicleClass,
+	).Scan(&id)
+	if err != nil {
+		pr.logger.Error("failed to create ride",
+			slog.Int("user_id", params.UserID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create ride: %w", err)
+	}
 
-	err := pr.db.QueryRowContext(ctx, "INSERT INTO rides (user_id, status, start_point, end_point, route) VALUES ($1, $2, $3, $4, $5) RETURNING id", userID, searchingStatus, start, end, route).Scan(&id)
+	err = tx.Commit()
 	if err != nil {
 		pr.logger.Error("failed to create ride",
-			slog.Int("user_id", userID),
+			slog.Int("user_id", params.UserID),
 			slog.String("error", err.Error()),
 		)
 		return nil, fmt.Errorf("failed to create ride: %w", err)
 	}
 
-	return &CreateResponse{id, searchingStatus, route}, nil
+	return &CreateResponse{
+		ID:       id,
+		Status:   searchingStatus,
+		Route:    params.Route,
+		Fare:     params.Fare,
+		Discount: params.Discount,
+	}, nil
 }
 
 func (pr *postgresRepo) GetRideByID(ctx context.Context, rideID int) (*Ride, error) {
 	var ride Ride
 
-	err := pr.db.QueryRowContext(ctx, "SELECT id, user_id, driver_id, status, start_point, end_point, route, created_at, updated_at FROM rides WHERE id = $1", rideID).Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Status, &ride.Start, &ride.End, &ride.Route, &ride.CreatedAt, &ride.UpdatedAt)
+	err := pr.db.QueryRowContext(ctx, "SELECT id, user_id, driver_id, status, start_point, end_point, route, fare, discount, promo_redemption_id, area_id, tariff_id, vehicle_class, vehicle_id, created_at, updated_at FROM rides WHERE id = $1", rideID).Scan(&ride.ID, &ride.UserID, &ride.DriverID, &ride.Status, &ride.Start, &ride.End, &ride.Route, &ride.Fare, &ride.Discount, &ride.PromoRedemptionID, &ride.AreaID, &ride.TariffID, &ride.VehicleClass, &ride.VehicleID, &ride.CreatedAt, &ride.UpdatedAt)
 	if err != nil {
 		pr.logger.Error("failed to get ride by ID",
 			slog.Int("ride_id", rideID),
 			slog.String("error", err.Error()),
 		)
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("ride with this id not found")
+			return nil, errRideNotFound
 		}
 		return nil, err
 	}
@@ -69,7 +132,7 @@ func (pr *postgresRepo) GetRideStatus(ctx context.Context, rideID int) (string,
 			slog.String("error", err.Error()),
 		)
 		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("ride with this id not found")
+			return "", errRideNotFound
 		}
 		return "", err
 	}
@@ -77,7 +140,9 @@ func (pr *postgresRepo) GetRideStatus(ctx context.Context, rideID int) (string,
 	return status, nil
 }
 
-func (pr *postgresRepo) TakeRide(ctx context.Context, rideID int, driverID int) (*ChangeRideResponse, error) {
+// TakeRide locks the ride row, so two drivers taking the same ride at once
+// can't both succeed.
+func (pr *postgresRepo) TakeRide(ctx context.Context, rideID, driverID, vehicleID int, vehicleClass string) (*ChangeRideResponse, error) {
 	tx, err := pr.db.BeginTxx(ctx, nil)
 	if err != nil {
 		pr.logger.Error("failed to take ride",
@@ -94,13 +159,33 @@ func (pr *postgresRepo) TakeRide(ctx context.Context, rideID int, driverID int)
 		}
 	}()
 
-	var status string
-	err = tx.Get(&status, "SELECT status FROM rides WHERE id = $1", rideID)
-	if status != searchingStatus {
-		return nil, fmt.Errorf("ride already taken")
+	var ride struct {
+		Status       string `db:"status"`
+		VehicleClass string `db:"vehicle_class"`
+	}
+	err = tx.GetContext(ctx, &ride, "SELECT status, vehicle_class FROM rides WHERE id = $1 FOR UPDATE", rideID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errRideNotFound
+		}
+		pr.logger.Error("failed to get ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to take ride: %w", err)
+	}
+
+	if ride.Status != searchingStatus {
+		err = errRideNotAvailable
+		return nil, err
 	}
 
-	_, err = tx.ExecContext(ctx, "UPDATE rides SET driver_id = $1, status = $2, updated_at = now() WHERE id = $3", driverID, inProgressStatus, rideID)
+	if ride.VehicleClass != vehicleClass {
+		err = errClassMismatch
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE rides SET driver_id = $1, vehicle_id = $2, status = $3, updated_at = now() WHERE id = $4", driverID, vehicleID, inProgressStatus, rideID)
 	if err != nil {
 		pr.logger.Error("failed to update ride",
 			slog.Int("driver_id", driverID),
@@ -136,8 +221,62 @@ func (pr *postgresRepo) CompleteRide(ctx context.Context, rideID int) (*ChangeRi
 	return NewChangeRideResponse(rideID, completedStatus), nil
 }
 
-func (pr *postgresRepo) CancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, error) {
-	_, err := pr.db.ExecContext(ctx, "UPDATE rides SET status = $1, updated_at = now() WHERE id = $2", canceledStatus, rideID)
+// CancelRide hands a promo redemption to release if the ride is canceled
+// before a driver took it. The release runs in the cancel's transaction.
+func (pr *postgresRepo) CancelRide(ctx context.Context, rideID int, release ReleaseFunc) (*ChangeRideResponse, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to cancel ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to cancel ride: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var ride struct {
+		Status            string `db:"status"`
+		PromoRedemptionID *int   `db:"promo_redemption_id"`
+	}
+	err = tx.GetContext(ctx, &ride, "SELECT status, promo_redemption_id FROM rides WHERE id = $1 FOR UPDATE", rideID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errRideNotFound
+		}
+		pr.logger.Error("failed to get ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to cancel ride: %w", err)
+	}
+
+	releasePromo := ride.Status == searchingStatus && ride.PromoRedemptionID != nil && release != nil
+	if releasePromo {
+		_, err = tx.ExecContext(ctx, "UPDATE rides SET status = $1, discount = 0, promo_redemption_id = NULL, updated_at = now() WHERE id = $2", canceledStatus, rideID)
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE rides SET status = $1, updated_at = now() WHERE id = $2", canceledStatus, rideID)
+	}
+	if err != nil {
+		pr.logger.Error("failed to cancel ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to cancel ride: %w", err)
+	}
+
+	if releasePromo {
+		err = release(tx, *ride.PromoRedemptionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		pr.logger.Error("failed to cancel ride",
 			slog.Int("ride_id", rideID),
@@ -149,10 +288,10 @@ func (pr *postgresRepo) CancelRide(ctx context.Context, rideID int) (*ChangeRide
 	return NewChangeRideResponse(rideID, canceledStatus), nil
 }
 
-func (pr *postgresRepo) GetSearchingRides(ctx context.Context) (*SearchRidesResponse, error) {
+func (pr *postgresRepo) GetSearchingRides(ctx context.Context, vehicleClass string) (*SearchRidesResponse, error) {
 	var rides []Ride
 
-	err := pr.db.SelectContext(ctx, &rides, "SELECT * FROM rides WHERE status = $1", searchingStatus)
+	err := pr.db.SelectContext(ctx, &rides, "SELECT * FROM rides WHERE status = $1 AND vehicle_class = $2", searchingStatus, vehicleClass)
 	if err != nil {
 		pr.logger.Error("failed to get rides",
 			slog.String("error", err.Error()),
@@ -166,3 +305,137 @@ func (pr *postgresRepo) GetSearchingRides(ctx context.Context) (*SearchRidesResp
 
 	return rideList, nil
 }
+
+func (pr *postgresRepo) ListRides(ctx context.Context, filter *RidesFilter) ([]Ride, error) {
+	rides := []Ride{}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.Status != "" {
+		addCondition("status = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		addCondition("user_id = ?", filter.UserID)
+	}
+	if filter.DriverID != 0 {
+		addCondition("driver_id = ?", filter.DriverID)
+	}
+	if filter.AreaID != 0 {
+		addCondition("area_id = ?", filter.AreaID)
+	}
+
+	query := "SELECT " + rideColumns + " FROM rides"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	err := pr.db.SelectContext(ctx, &rides, query, args...)
+	if err != nil {
+		pr.logger.Error("failed to list rides",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list rides: %w", err)
+	}
+
+	return rides, nil
+}
+
+// ForceCancelRide cancels the ride whatever its stage, as long as it hasn't
+// finished yet.
+func (pr *postgresRepo) ForceCancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, error) {
+	var id int
+
+	err := pr.db.QueryRowContext(ctx, "UPDATE rides SET status = $1, updated_at = now() WHERE id = $2 AND status = ANY($3) RETURNING id",
+		canceledStatus, rideID, pq.Array([]string{searchingStatus, inProgressStatus}),
+	).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if _, getErr := pr.GetRideStatus(ctx, rideID); getErr != nil {
+				return nil, getErr
+			}
+			return nil, errRideFinished
+		}
+		pr.logger.Error("failed to force cancel ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to cancel ride: %w", err)
+	}
+
+	return NewChangeRideResponse(rideID, canceledStatus), nil
+}
+
+// ReassignRide hands the ride over to another driver. Like TakeRide it locks
+// the ride row, so it can't race with a driver taking the ride.
+func (pr *postgresRepo) ReassignRide(ctx context.Context, rideID, driverID, vehicleID int, vehicleClass string) (*ChangeRideResponse, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to reassign ride",
+			slog.Int("driver_id", driverID),
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to reassign ride: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var ride struct {
+		Status       string `db:"status"`
+		VehicleClass string `db:"vehicle_class"`
+	}
+	err = tx.GetContext(ctx, &ride, "SELECT status, vehicle_class FROM rides WHERE id = $1 FOR UPDATE", rideID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errRideNotFound
+		}
+		pr.logger.Error("failed to get ride",
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to reassign ride: %w", err)
+	}
+
+	if ride.Status != searchingStatus && ride.Status != inProgressStatus {
+		err = errRideFinished
+		return nil, err
+	}
+
+	if ride.VehicleClass != vehicleClass {
+		err = errClassMismatch
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE rides SET driver_id = $1, vehicle_id = $2, status = $3, updated_at = now() WHERE id = $4", driverID, vehicleID, inProgressStatus, rideID)
+	if err != nil {
+		pr.logger.Error("failed to update ride",
+			slog.Int("driver_id", driverID),
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to reassign ride: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to reassign ride",
+			slog.Int("driver_id", driverID),
+			slog.Int("ride_id", rideID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to reassign ride: %w", err)
+	}
+
+	return NewChangeRideResponse(rideID, inProgressStatus), nil
+}
@@ -3,36 +3,100 @@ package rides
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/areas"
+	"github.com/AzizovHikmatullo/go-ride/internal/drivers"
+	"github.com/AzizovHikmatullo/go-ride/internal/promotions"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
+	"github.com/AzizovHikmatullo/go-ride/internal/tariffs"
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
+	"github.com/jmoiron/sqlx"
 )
 
 type RepositoryInterface interface {
-	CreateRide(ctx context.Context, userID int, start, end []byte, route json.RawMessage) (*CreateResponse, error)
+	CreateRide(ctx context.Context, params *CreateRideParams, redeem RedeemFunc) (*CreateResponse, error)
 	GetRideByID(ctx context.Context, rideID int) (*Ride, error)
 	GetRideStatus(ctx context.Context, rideID int) (string, error)
-	TakeRide(ctx context.Context, rideID int, driverID int) (*ChangeRideResponse, error)
+	TakeRide(ctx context.Context, rideID, driverID, vehicleID int, vehicleClass string) (*ChangeRideResponse, error)
 	CompleteRide(ctx context.Context, rideID int) (*ChangeRideResponse, error)
-	CancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, error)
-	GetSearchingRides(ctx context.Context) (*SearchRidesResponse, error)
+	CancelRide(ctx context.Context, rideID int, release ReleaseFunc) (*ChangeRideResponse, error)
+	GetSearchingRides(ctx context.Context, vehicleClass string) (*SearchRidesResponse, error)
+	ListRides(ctx context.Context, filter *RidesFilter) ([]Ride, error)
+	ForceCancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, error)
+	ReassignRide(ctx context.Context, rideID, driverID, vehicleID int, vehicleClass string) (*ChangeRideResponse, error)
+}
+
+type PromoRedeemer interface {
+	Redeem(ctx context.Context, tx *sqlx.Tx, code string, userID int, fare float64, city string) (*promotions.Redemption, error)
+	Release(ctx context.Context, tx *sqlx.Tx, redemptionID int) error
+}
+
+type AreaLocator interface {
+	Locate(ctx context.Context, lon, lat float64) (*areas.Area, error)
+}
+
+type TariffResolver interface {
+	ActiveTariff(ctx context.Context, areaID int, vehicleClass string, at time.Time) (*tariffs.Tariff, error)
+}
+
+type VehicleLookup interface {
+	ActiveVehicle(ctx context.Context, driverID int) (*vehicles.Vehicle, error)
+}
+
+type DriverStatusProvider interface {
+	DriverStatus(ctx context.Context, userID int) (string, error)
 }
 
 type RideService struct {
-	repo   RepositoryInterface
-	logger *slog.Logger
+	repo     RepositoryInterface
+	promos   PromoRedeemer
+	areas    AreaLocator
+	tariffs  TariffResolver
+	vehicles VehicleLookup
+	drivers  DriverStatusProvider
+	cfg      Config
+	logger   *slog.Logger
 }
 
-func NewRideService(repository RepositoryInterface, logger *slog.Logger) RideServiceInterface {
+func NewRideService(repository RepositoryInterface, promos PromoRedeemer, locator AreaLocator, resolver TariffResolver, lookup VehicleLookup, drivers DriverStatusProvider, cfg Config, logger *slog.Logger) RideServiceInterface {
 	return &RideService{
-		repo:   repository,
-		logger: logger,
+		repo:     repository,
+		promos:   promos,
+		areas:    locator,
+		tariffs:  resolver,
+		vehicles: lookup,
+		drivers:  drivers,
+		cfg:      cfg,
+		logger:   logger,
 	}
 }
 
-func (rs *RideService) CreateRide(ctx context.Context, userID int, start, end PointGeoJSON) (*CreateResponse, *ErrorResponse) {
-	route, err := fetchRideFromAPI(ctx, start, end)
+func (rs *RideService) CreateRide(ctx context.Context, userID int, body *CreateRequest) (*CreateResponse, *ErrorResponse) {
+	if body.VehicleClass == "" {
+		body.VehicleClass = vehicles.EconomyClass
+	}
+
+	if fieldErrs := body.Validate(rs.cfg.MaxTripDistanceKm); len(fieldErrs) > 0 {
+		return nil, NewValidationErrorResponse(fieldErrs)
+	}
+
+	pickupArea, errResp := rs.locate(ctx, "start_point", body.Start)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	if _, errResp := rs.locate(ctx, "end_point", body.End); errResp != nil {
+		return nil, errResp
+	}
+
+	route, err := fetchRideFromAPI(ctx, body.Start, body.End)
 	if err != nil {
 		rs.logger.Error("failed to fetch route from API",
 			slog.String("error", err.Error()),
@@ -40,23 +104,57 @@ func (rs *RideService) CreateRide(ctx context.Context, userID int, start, end Po
 		return nil, NewErrorResponse(err)
 	}
 
-	routeJSON, err := json.Marshal(route)
+	routeJSON, err := json.Marshal(route.Geometry)
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
-	startJSON, err := json.Marshal(start)
+	startJSON, err := json.Marshal(body.Start)
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
-	endJSON, err := json.Marshal(end)
+	endJSON, err := json.Marshal(body.End)
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 
-	response, err := rs.repo.CreateRide(ctx, userID, startJSON, endJSON, routeJSON)
+	vehicleClass, _ := vehicles.NormalizeClass(body.VehicleClass)
+
+	params := &CreateRideParams{
+		UserID:       userID,
+		Start:        startJSON,
+		End:          endJSON,
+		Route:        routeJSON,
+		AreaID:       pickupArea.ID,
+		VehicleClass: vehicleClass,
+	}
+
+	rates := rs.cfg.DefaultRates
+	tariff, err := rs.tariffs.ActiveTariff(ctx, pickupArea.ID, vehicleClass, time.Now())
+	switch {
+	case err == nil:
+		rates = tariff.Rates
+		params.TariffID = &tariff.ID
+	case !errors.Is(err, tariffs.ErrTariffNotFound):
+		return nil, NewErrorResponse(err)
+	}
+	params.Fare = rates.Price(route.Distance, route.Duration)
+
+	// The fare is locked in here, so this is the moment the promo code is
+	// consumed, together with inserting the ride.
+	var redeem RedeemFunc
+	if body.PromoCode != "" {
+		redeem = func(tx *sqlx.Tx) (*promotions.Redemption, error) {
+			return rs.promos.Redeem(ctx, tx, body.PromoCode, userID, params.Fare, pickupArea.City)
+		}
+	}
+
+	response, err := rs.repo.CreateRide(ctx, params, redeem)
 	if err != nil {
+		if errors.Is(err, promotions.ErrInvalidPromo) {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, err)
+		}
 		return nil, NewErrorResponse(err)
 	}
 
@@ -85,8 +183,21 @@ func (rs *RideService) GetRideStatus(ctx context.Context, rideID int) (string, *
 }
 
 func (rs *RideService) TakeRide(ctx context.Context, rideID int, driverID int) (*ChangeRideResponse, *ErrorResponse) {
-	response, err := rs.repo.TakeRide(ctx, rideID, driverID)
+	vehicle, errResp := rs.activeVehicle(ctx, driverID)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	response, err := rs.repo.TakeRide(ctx, rideID, driverID, vehicle.ID, vehicle.Class)
 	if err != nil {
+		switch {
+		case errors.Is(err, errRideNotFound):
+			return nil, NewErrorResponseWithStatus(http.StatusNotFound, err)
+		case errors.Is(err, errRideNotAvailable):
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, err)
+		case errors.Is(err, errClassMismatch):
+			return nil, NewErrorResponseWithStatus(http.StatusForbidden, err)
+		}
 		return nil, NewErrorResponse(err)
 	}
 
@@ -112,7 +223,9 @@ func (rs *RideService) CompleteRide(ctx context.Context, rideID int) (*ChangeRid
 }
 
 func (rs *RideService) CancelRide(ctx context.Context, rideID int) (*ChangeRideResponse, *ErrorResponse) {
-	response, err := rs.repo.CancelRide(ctx, rideID)
+	response, err := rs.repo.CancelRide(ctx, rideID, func(tx *sqlx.Tx, redemptionID int) error {
+		return rs.promos.Release(ctx, tx, redemptionID)
+	})
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
@@ -124,32 +237,143 @@ func (rs *RideService) CancelRide(ctx context.Context, rideID int) (*ChangeRideR
 	return response, nil
 }
 
-func (rs *RideService) GetSearchingRides(ctx context.Context) (*SearchRidesResponse, *ErrorResponse) {
-	rides, err := rs.repo.GetSearchingRides(ctx)
+// GetSearchingRides only returns rides the driver's active vehicle can serve.
+func (rs *RideService) GetSearchingRides(ctx context.Context, driverID int) (*SearchRidesResponse, *ErrorResponse) {
+	vehicle, errResp := rs.activeVehicle(ctx, driverID)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	rides, err := rs.repo.GetSearchingRides(ctx, vehicle.Class)
 	if err != nil {
 		return nil, NewErrorResponse(err)
 	}
 	return rides, nil
 }
 
+func (rs *RideService) ListRides(ctx context.Context, filter *RidesFilter) (*SearchRidesResponse, *ErrorResponse) {
+	filter.normalize()
+	filter.Status = strings.ToUpper(filter.Status)
+
+	rides, err := rs.repo.ListRides(ctx, filter)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &SearchRidesResponse{Rides: rides}, nil
+}
+
+func (rs *RideService) ForceCancelRide(ctx context.Context, rideID, adminID int) (*ChangeRideResponse, *ErrorResponse) {
+	response, err := rs.repo.ForceCancelRide(ctx, rideID)
+	if err != nil {
+		return nil, rideErrorResponse(err)
+	}
+
+	rs.logger.Info("admin canceled ride",
+		slog.Int("ride_id", rideID),
+		slog.Int("admin_id", adminID),
+	)
+
+	return response, nil
+}
+
+// ReassignRide gives the ride to another approved driver whose active vehicle
+// matches the ride's class.
+func (rs *RideService) ReassignRide(ctx context.Context, rideID, adminID int, body *ReassignRequest) (*ChangeRideResponse, *ErrorResponse) {
+	status, err := rs.drivers.DriverStatus(ctx, body.DriverID)
+	if err != nil {
+		if errors.Is(err, drivers.ErrApplicationNotFound) {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, errDriverNotApproved)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	if status != drivers.ApprovedStatus {
+		return nil, NewErrorResponseWithStatus(http.StatusConflict, errDriverNotApproved)
+	}
+
+	vehicle, err := rs.vehicles.ActiveVehicle(ctx, body.DriverID)
+	if err != nil {
+		if errors.Is(err, vehicles.ErrNoActiveVehicle) {
+			return nil, NewErrorResponseWithStatus(http.StatusConflict, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+
+	response, err := rs.repo.ReassignRide(ctx, rideID, body.DriverID, vehicle.ID, vehicle.Class)
+	if err != nil {
+		return nil, rideErrorResponse(err)
+	}
+
+	rs.logger.Info("admin reassigned ride",
+		slog.Int("ride_id", rideID),
+		slog.Int("driver_id", body.DriverID),
+		slog.Int("admin_id", adminID),
+	)
+
+	return response, nil
+}
+
+func (rs *RideService) activeVehicle(ctx context.Context, driverID int) (*vehicles.Vehicle, *ErrorResponse) {
+	vehicle, err := rs.vehicles.ActiveVehicle(ctx, driverID)
+	if err != nil {
+		if errors.Is(err, vehicles.ErrNoActiveVehicle) {
+			return nil, NewErrorResponseWithStatus(http.StatusForbidden, err)
+		}
+		return nil, NewErrorResponse(err)
+	}
+	return vehicle, nil
+}
+
+// CheckAccess allows roles that may read any ride, the ride's rider and, for
+// roles that take rides, the assigned driver or any driver while the ride is
+// unassigned.
 func (s *RideService) CheckAccess(rideID, userID int, role string) error {
 	ride, errResp := s.GetRideByID(context.Background(), rideID)
 	if errResp != nil {
 		return fmt.Errorf("failed to get ride: %s", errResp.Message)
 	}
 
-	if role == "USER" && ride.UserID != userID {
+	if rbac.Can(role, rbac.RidesReadAny) || ride.UserID == userID {
+		return nil
+	}
+
+	if !rbac.Can(role, rbac.RidesTake) {
 		return fmt.Errorf("this ride does not belong to you")
 	}
 
-	if role == "DRIVER" && ride.DriverID != nil && *ride.DriverID != userID {
+	if ride.DriverID != nil && *ride.DriverID != userID {
 		return fmt.Errorf("you are not assigned to this ride")
 	}
 
 	return nil
 }
 
-func fetchRideFromAPI(ctx context.Context, start, end PointGeoJSON) (*Geometry, error) {
+func rideErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, errRideNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errRideFinished), errors.Is(err, errClassMismatch):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
+
+func (rs *RideService) locate(ctx context.Context, field string, point PointGeoJSON) (*areas.Area, *ErrorResponse) {
+	area, err := rs.areas.Locate(ctx, point.Coordinates[0], point.Coordinates[1])
+	if err != nil {
+		if errors.Is(err, areas.ErrOutsideServiceArea) {
+			return nil, NewValidationErrorResponse([]FieldError{{Field: field, Message: err.Error()}})
+		}
+		return nil, NewErrorResponse(err)
+	}
+	return area, nil
+}
+
+func fetchRideFromAPI(ctx context.Context, start, end PointGeoJSON) (*Route, error) {
+	if len(start.Coordinates) < minCoordinateCount || len(end.Coordinates) < minCoordinateCount {
+		return nil, fmt.Errorf("invalid route points")
+	}
+
 	url := fmt.Sprintf(
 		"http://router.project-osrm.org/route/v1/driving/%f,%f;%f,%f?geometries=geojson",
 		start.Coordinates[0], start.Coordinates[1], end.Coordinates[0], end.Coordinates[1],
@@ -189,5 +413,5 @@ func fetchRideFromAPI(ctx context.Context, start, end PointGeoJSON) (*Geometry,
 		return nil, fmt.Errorf("no routes found")
 	}
 
-	return &apiResp.Routes[0].Geometry, nil
+	return &apiResp.Routes[0], nil
 }
@@ -0,0 +1,97 @@
+package rides
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
+)
+
+const (
+	pointType          = "Point"
+	earthRadiusKm      = 6371.0
+	minCoordinateCount = 2
+	maxCoordinateCount = 3
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks the request before any routing call is made, so malformed
+// points never reach fetchRideFromAPI. A maxDistanceKm of zero disables the
+// trip length check.
+func (r *CreateRequest) Validate(maxDistanceKm float64) []FieldError {
+	var errs []FieldError
+
+	errs = append(errs, validatePoint("start_point", r.Start)...)
+	errs = append(errs, validatePoint("end_point", r.End)...)
+
+	if _, ok := vehicles.NormalizeClass(r.VehicleClass); !ok {
+		errs = append(errs, FieldError{Field: "vehicle_class", Message: "must be one of " + strings.Join(vehicles.Classes, ", ")})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if r.Start.Coordinates[0] == r.End.Coordinates[0] && r.Start.Coordinates[1] == r.End.Coordinates[1] {
+		return []FieldError{{Field: "end_point", Message: "must differ from start_point"}}
+	}
+
+	if maxDistanceKm > 0 {
+		distance := haversineKm(r.Start, r.End)
+		if distance > maxDistanceKm {
+			return []FieldError{{
+				Field:   "end_point",
+				Message: fmt.Sprintf("trip distance %.1f km exceeds the maximum of %.1f km", distance, maxDistanceKm),
+			}}
+		}
+	}
+
+	return nil
+}
+
+func validatePoint(field string, point PointGeoJSON) []FieldError {
+	var errs []FieldError
+
+	if point.Type != pointType {
+		errs = append(errs, FieldError{Field: field + ".type", Message: `must be "Point"`})
+	}
+
+	if len(point.Coordinates) < minCoordinateCount || len(point.Coordinates) > maxCoordinateCount {
+		return append(errs, FieldError{Field: field + ".coordinates", Message: "must contain 2 or 3 numbers"})
+	}
+
+	for i, value := range point.Coordinates {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("%s.coordinates[%d]", field, i), Message: "must be a finite number"})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if lon := point.Coordinates[0]; lon < -180 || lon > 180 {
+		errs = append(errs, FieldError{Field: field + ".coordinates[0]", Message: "longitude must be between -180 and 180"})
+	}
+
+	if lat := point.Coordinates[1]; lat < -90 || lat > 90 {
+		errs = append(errs, FieldError{Field: field + ".coordinates[1]", Message: "latitude must be between -90 and 90"})
+	}
+
+	return errs
+}
+
+// haversineKm returns the great-circle distance between two points.
+func haversineKm(a, b PointGeoJSON) float64 {
+	lat1 := a.Coordinates[1] * math.Pi / 180
+	lat2 := b.Coordinates[1] * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Coordinates[0] - a.Coordinates[0]) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
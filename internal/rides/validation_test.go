@@ -0,0 +1,70 @@
+package rides
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func point(coordinates ...float64) PointGeoJSON {
+	return PointGeoJSON{Type: pointType, Coordinates: coordinates}
+}
+
+func TestCreateRequestValidate(t *testing.T) {
+	start := point(68.7791, 38.5598)
+	end := point(68.8000, 38.5800)
+
+	tests := []struct {
+		name          string
+		request       CreateRequest
+		maxDistanceKm float64
+		wantFields    []string
+	}{
+		{"valid", CreateRequest{Start: start, End: end, VehicleClass: "ECONOMY"}, 100, nil},
+		{"class is case insensitive", CreateRequest{Start: start, End: end, VehicleClass: " comfort "}, 100, nil},
+		{"with altitude", CreateRequest{Start: point(68.7791, 38.5598, 800), End: end, VehicleClass: "XL"}, 100, nil},
+		{"unknown class", CreateRequest{Start: start, End: end, VehicleClass: "LIMO"}, 100, []string{"vehicle_class"}},
+		{"wrong type", CreateRequest{Start: PointGeoJSON{Type: "LineString", Coordinates: start.Coordinates}, End: end, VehicleClass: "ECONOMY"}, 100, []string{"start_point.type"}},
+		{"too few coordinates", CreateRequest{Start: start, End: point(68.8), VehicleClass: "ECONOMY"}, 100, []string{"end_point.coordinates"}},
+		{"too many coordinates", CreateRequest{Start: point(1, 2, 3, 4), End: end, VehicleClass: "ECONOMY"}, 100, []string{"start_point.coordinates"}},
+		{"not finite", CreateRequest{Start: point(math.NaN(), math.Inf(1)), End: end, VehicleClass: "ECONOMY"}, 100, []string{"start_point.coordinates[0]", "start_point.coordinates[1]"}},
+		{"out of range", CreateRequest{Start: point(181, -91), End: end, VehicleClass: "ECONOMY"}, 100, []string{"start_point.coordinates[0]", "start_point.coordinates[1]"}},
+		{"every field", CreateRequest{VehicleClass: "LIMO"}, 100, []string{"start_point.type", "start_point.coordinates", "end_point.type", "end_point.coordinates", "vehicle_class"}},
+		{"same points", CreateRequest{Start: start, End: point(68.7791, 38.5598, 900), VehicleClass: "ECONOMY"}, 100, []string{"end_point"}},
+		{"too far", CreateRequest{Start: start, End: point(69.6300, 40.2800), VehicleClass: "ECONOMY"}, 100, []string{"end_point"}},
+		{"distance check disabled", CreateRequest{Start: start, End: point(69.6300, 40.2800), VehicleClass: "ECONOMY"}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, fieldErr := range tt.request.Validate(tt.maxDistanceKm) {
+				fields = append(fields, fieldErr.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("Validate fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b PointGeoJSON
+		want float64
+	}{
+		{"same point", point(68.78, 38.56), point(68.78, 38.56), 0},
+		{"one degree of latitude", point(0, 0), point(0, 1), 111.19},
+		{"one degree of longitude at the equator", point(0, 0), point(1, 0), 111.19},
+		{"antimeridian", point(179.5, 0), point(-179.5, 0), 111.19},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := haversineKm(tt.a, tt.b); math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("haversineKm = %.3f, want %.2f", got, tt.want)
+			}
+		})
+	}
+}
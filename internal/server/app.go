@@ -9,10 +9,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/AzizovHikmatullo/go-ride/internal/apikeys"
+	"github.com/AzizovHikmatullo/go-ride/internal/areas"
+	"github.com/AzizovHikmatullo/go-ride/internal/audit"
 	"github.com/AzizovHikmatullo/go-ride/internal/auth"
 	"github.com/AzizovHikmatullo/go-ride/internal/config"
+	"github.com/AzizovHikmatullo/go-ride/internal/drivers"
+	"github.com/AzizovHikmatullo/go-ride/internal/idempotency"
+	"github.com/AzizovHikmatullo/go-ride/internal/mailer"
 	"github.com/AzizovHikmatullo/go-ride/internal/middleware"
+	"github.com/AzizovHikmatullo/go-ride/internal/oidc"
+	"github.com/AzizovHikmatullo/go-ride/internal/profile"
+	"github.com/AzizovHikmatullo/go-ride/internal/promotions"
+	"github.com/AzizovHikmatullo/go-ride/internal/rbac"
 	"github.com/AzizovHikmatullo/go-ride/internal/rides"
+	"github.com/AzizovHikmatullo/go-ride/internal/sms"
+	"github.com/AzizovHikmatullo/go-ride/internal/storage"
+	"github.com/AzizovHikmatullo/go-ride/internal/tariffs"
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 
@@ -26,6 +40,9 @@ type App struct {
 	logger *slog.Logger
 	db     *sqlx.DB
 	r      *gin.Engine
+
+	// jobs run in the background for the lifetime of the server.
+	jobs []func(ctx context.Context)
 }
 
 func NewApp(cfg *config.Config, db *sqlx.DB, logger *slog.Logger) *App {
@@ -53,9 +70,22 @@ func NewApp(cfg *config.Config, db *sqlx.DB, logger *slog.Logger) *App {
 // @securityDefinitions.apikey DriverAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.apikey AdminAuth
+// @in header
+// @name Authorization
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 func (a *App) Run() {
 	a.InitRoutes()
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	for _, job := range a.jobs {
+		go job(jobsCtx)
+	}
+
 	srv := &http.Server{
 		Addr:    ":" + a.cfg.Server.Port,
 		Handler: a.r,
@@ -76,6 +106,8 @@ func (a *App) Run() {
 
 	a.logger.Info("Shutting down server...")
 
+	stopJobs()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
@@ -89,16 +121,150 @@ func (a *App) Run() {
 }
 
 func (a *App) InitRoutes() {
+	// Client IPs feed the login throttle, sessions and the audit log, so
+	// forwarding headers are only believed from configured proxies.
+	if err := a.r.SetTrustedProxies(a.cfg.Server.TrustedProxies); err != nil {
+		a.logger.Error("Failed to set trusted proxies", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	a.r.Use(middleware.LoggerMiddleware(a.logger))
 
+	blobStore, err := storage.NewLocalStore(a.cfg.Storage.LocalDir)
+	if err != nil {
+		a.logger.Error("Failed to init blob storage", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	mailSender, err := a.newMailer()
+	if err != nil {
+		a.logger.Error("Failed to init mailer", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	smsSender, err := a.newSMSSender()
+	if err != nil {
+		a.logger.Error("Failed to init sms sender", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
 	authRepo := auth.NewRepository(a.db, a.logger)
+	apiKeysRepo := apikeys.NewRepository(a.db, a.logger)
+	profileRepo := profile.NewRepository(a.db, a.logger)
 	ridesRepo := rides.NewRepository(a.db, a.logger)
+	promosRepo := promotions.NewRepository(a.db, a.logger)
+	areasRepo := areas.NewRepository(a.db, a.logger)
+	tariffsRepo := tariffs.NewRepository(a.db, a.logger)
+	vehiclesRepo := vehicles.NewRepository(a.db, a.logger)
+	driversRepo := drivers.NewRepository(a.db, a.logger)
+	auditRepo := audit.NewRepository(a.db, a.logger)
+	idempotencyStore := idempotency.NewRepository(a.db, a.cfg.Idempotency.KeyTTL, a.logger)
+
+	ridesCfg := rides.Config{
+		DefaultRates: tariffs.Rates{
+			BaseFare:    a.cfg.Fare.Base,
+			PerKm:       a.cfg.Fare.PerKm,
+			PerMinute:   a.cfg.Fare.PerMinute,
+			MinimumFare: a.cfg.Fare.Minimum,
+		},
+		MaxTripDistanceKm: a.cfg.Rides.MaxTripDistanceKm,
+	}
+
+	var signingKeys *auth.KeySet
+	if a.cfg.JWT.KeysDir != "" {
+		signingKeys, err = auth.LoadKeySet(a.cfg.JWT.KeysDir, a.cfg.JWT.SigningKeyID)
+		if err != nil {
+			a.logger.Error("Failed to load JWT signing keys", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	authCfg := auth.Config{
+		JWTSecret:              a.cfg.JWT.Secret,
+		SigningKeys:            signingKeys,
+		AcceptLegacyHS256Until: a.cfg.JWT.AcceptLegacyHS256Until,
+		Issuer:                 a.cfg.JWT.Issuer,
+		Audience:               a.cfg.JWT.Audience,
+		Leeway:                 a.cfg.JWT.Leeway,
+		AccessTokenTTL:         a.cfg.JWT.AccessTokenTTL,
+		RefreshTokenTTL:        a.cfg.JWT.RefreshTokenTTL,
+		TokenVersionCacheTTL:   a.cfg.JWT.VersionCacheTTL,
+		VerificationTokenTTL:   a.cfg.EmailVerification.TokenTTL,
+		RequireVerifiedEmail:   a.cfg.EmailVerification.Required,
+		VerificationURL:        a.cfg.EmailVerification.URL,
+
+		LoginThrottle: auth.LoginThrottle{
+			MaxAttempts:      a.cfg.LoginThrottle.MaxAttempts,
+			MaxAttemptsPerIP: a.cfg.LoginThrottle.MaxAttemptsPerIP,
+			Window:           a.cfg.LoginThrottle.Window,
+			LockoutBase:      a.cfg.LoginThrottle.LockoutBase,
+			LockoutMax:       a.cfg.LoginThrottle.LockoutMax,
+		},
+
+		PasswordResetTokenTTL: a.cfg.PasswordReset.TokenTTL,
+		PasswordResetURL:      a.cfg.PasswordReset.URL,
+
+		OTP: auth.OTPConfig{
+			CodeTTL:        a.cfg.OTP.CodeTTL,
+			ResendInterval: a.cfg.OTP.ResendInterval,
+			MaxSends:       a.cfg.OTP.MaxSends,
+			SendWindow:     a.cfg.OTP.SendWindow,
+			MaxAttempts:    a.cfg.OTP.MaxAttempts,
+		},
+
+		OIDCProviders: make(map[string]auth.OIDCProvider),
+		OIDCStateTTL:  a.cfg.OIDC.StateTTL,
+	}
+	for _, provider := range a.cfg.OIDC.Providers {
+		authCfg.OIDCProviders[provider.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		})
+	}
 
-	authService := auth.NewAuthService(authRepo, a.cfg.JWT.Secret, a.cfg.JWT.AccessTokenTTL, a.cfg.JWT.RefreshTokenTTL, a.logger)
-	ridesService := rides.NewRideService(ridesRepo, a.logger)
+	auditService := audit.NewAuditService(auditRepo, a.logger)
+	authService := auth.NewAuthService(authRepo, mailSender, smsSender, auditService, authCfg, a.logger)
+	a.jobs = append(a.jobs, func(ctx context.Context) {
+		authService.RunCleanup(ctx, a.cfg.JWT.CleanupInterval)
+	})
+	a.jobs = append(a.jobs, func(ctx context.Context) {
+		idempotency.RunCleanup(ctx, idempotencyStore, a.cfg.JWT.CleanupInterval, a.logger)
+	})
+	apiKeysService := apikeys.NewAPIKeyService(apiKeysRepo, a.logger)
+	promosService := promotions.NewPromoService(promosRepo, a.logger)
+	areasService := areas.NewAreaService(areasRepo, a.logger)
+	tariffsService := tariffs.NewTariffService(tariffsRepo, a.logger)
+	vehiclesService := vehicles.NewVehicleService(vehiclesRepo, a.logger)
+	driversService := drivers.NewDriverService(driversRepo, blobStore, a.logger)
+	profileService := profile.NewProfileService(profileRepo, blobStore, authService, auditService, a.logger)
+	ridesService := rides.NewRideService(ridesRepo, promosService, areasService, tariffsService, vehiclesService, driversService, ridesCfg, a.logger)
 
 	authHandler := auth.NewAuthHandler(authService)
+	apiKeysHandler := apikeys.NewAPIKeyHandler(apiKeysService)
+	profileHandler := profile.NewProfileHandler(profileService)
 	ridesHandler := rides.NewRideHandler(ridesService)
+	promosHandler := promotions.NewPromoHandler(promosService)
+	areasHandler := areas.NewAreaHandler(areasService)
+	tariffsHandler := tariffs.NewTariffHandler(tariffsService)
+	vehiclesHandler := vehicles.NewVehicleHandler(vehiclesService)
+	driversHandler := drivers.NewDriverHandler(driversService)
+	auditHandler := audit.NewAuditHandler(auditService)
+
+	tokenVerifier := auth.NewTokenVerifier(authCfg)
+	authenticated := middleware.AuthMiddleware(tokenVerifier, authService)
+	authenticatedOrAPIKey := middleware.AuthOrAPIKeyMiddleware(tokenVerifier, authService, apiKeysService)
+	requireMFA := middleware.RequireMFA(authService)
+	approvedDriver := middleware.RequireApprovedDriver(driversService)
+
+	verifiedEmail := func(c *gin.Context) { c.Next() }
+	if a.cfg.EmailVerification.Required == auth.VerificationForRides {
+		verifiedEmail = middleware.RequireVerifiedEmail(authService)
+	}
+
+	a.r.GET("/.well-known/jwks.json", authHandler.JWKS)
 
 	authRoutes := a.r.Group("/auth")
 	{
@@ -106,22 +272,148 @@ func (a *App) InitRoutes() {
 		authRoutes.POST("/login", authHandler.Login)
 		authRoutes.POST("/refresh", authHandler.RefreshToken)
 		authRoutes.POST("/logout", authHandler.Logout)
+		authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/verify-email/resend", authHandler.ResendVerification)
+		authRoutes.POST("/password/forgot", authHandler.ForgotPassword)
+		authRoutes.POST("/password/reset", authHandler.ResetPassword)
+		authRoutes.POST("/password/change", authenticated, requireMFA, authHandler.ChangePassword)
+		authRoutes.GET("/sessions", authenticated, requireMFA, authHandler.ListSessions)
+		authRoutes.DELETE("/sessions/:id", authenticated, requireMFA, authHandler.RevokeSession)
+		authRoutes.POST("/logout-all", authenticated, authHandler.LogoutAll)
+		authRoutes.POST("/otp/request", authHandler.RequestOTP)
+		authRoutes.POST("/otp/verify", authHandler.VerifyOTP)
+		authRoutes.GET("/oidc/:provider/authorize", authHandler.StartOIDCLogin)
+		authRoutes.GET("/oidc/:provider/callback", authHandler.FinishOIDCLogin)
+		authRoutes.POST("/mfa/verify", authHandler.VerifyMFA)
+		authRoutes.POST("/mfa/enroll", authenticated, authHandler.EnrollMFA)
+		authRoutes.POST("/mfa/enable", authenticated, authHandler.EnableMFA)
+		authRoutes.POST("/mfa/disable", authenticated, authHandler.DisableMFA)
+		authRoutes.POST("/mfa/recovery-codes", authenticated, authHandler.RegenerateRecoveryCodes)
 	}
 
+	meGroup := a.r.Group("/me")
+	meGroup.Use(authenticated, requireMFA)
+	{
+		meGroup.GET("", profileHandler.GetProfile)
+		meGroup.PATCH("", profileHandler.UpdateProfile)
+		meGroup.DELETE("", profileHandler.DeleteAccount)
+		meGroup.GET("/export", profileHandler.Export)
+		meGroup.PUT("/avatar", profileHandler.SetAvatar)
+		meGroup.GET("/avatar", profileHandler.GetAvatar)
+		meGroup.DELETE("/avatar", profileHandler.DeleteAvatar)
+	}
+
+	idempotent := middleware.IdempotencyMiddleware(idempotencyStore, a.logger)
+
 	ridesGroup := a.r.Group("/rides")
-	ridesGroup.Use(middleware.AuthMiddleware())
+	ridesGroup.Use(authenticated, requireMFA, idempotent)
+	{
+		ridesGroup.GET("/search", middleware.RequirePermission(rbac.RidesSearch), approvedDriver, ridesHandler.GetSearchingRides)
+		ridesGroup.POST("/:id/take", middleware.RequirePermission(rbac.RidesTake), approvedDriver, ridesHandler.TakeRide)
+		ridesGroup.POST("/:id/complete", middleware.RequirePermission(rbac.RidesComplete), approvedDriver, ridesHandler.CompleteRide)
+	}
+
+	// Rider routes also accept API keys, so partners can book rides for
+	// the users their keys were issued for.
+	riderGroup := a.r.Group("/rides")
+	riderGroup.Use(authenticatedOrAPIKey, requireMFA, idempotent)
+	{
+		riderGroup.POST("", middleware.RequirePermission(rbac.RidesCreate), verifiedEmail, ridesHandler.CreateRide)
+		riderGroup.GET("/:id", middleware.RequirePermission(rbac.RidesRead), ridesHandler.GetRideByID)
+		riderGroup.GET("/:id/status", middleware.RequirePermission(rbac.RidesRead), ridesHandler.GetRideStatus)
+		riderGroup.POST("/:id/cancel", middleware.RequirePermission(rbac.RidesCancel), ridesHandler.CancelRide)
+	}
+
+	promosGroup := a.r.Group("/promos")
+	promosGroup.Use(authenticated, requireMFA)
+	{
+		promosGroup.POST("/apply", middleware.RequirePermission(rbac.PromosApply), promosHandler.Apply)
+	}
+
+	vehiclesGroup := a.r.Group("/vehicles")
+	vehiclesGroup.Use(authenticated, requireMFA, middleware.RequirePermission(rbac.VehiclesManage), approvedDriver)
 	{
-		ridesGroup.GET("/search", middleware.RequireRole("DRIVER"), ridesHandler.GetSearchingRides)
-		ridesGroup.POST("/:id/take", middleware.RequireRole("DRIVER"), ridesHandler.TakeRide)
-		ridesGroup.POST("/:id/complete", middleware.RequireRole("DRIVER"), ridesHandler.CompleteRide)
+		vehiclesGroup.POST("", vehiclesHandler.CreateVehicle)
+		vehiclesGroup.GET("", vehiclesHandler.ListVehicles)
+		vehiclesGroup.GET("/:id", vehiclesHandler.GetVehicle)
+		vehiclesGroup.PUT("/:id", vehiclesHandler.UpdateVehicle)
+		vehiclesGroup.DELETE("/:id", vehiclesHandler.DeleteVehicle)
+	}
 
-		ridesGroup.POST("", middleware.RequireRole("USER"), ridesHandler.CreateRide)
-		ridesGroup.GET("/:id", middleware.RequireRole("USER"), ridesHandler.GetRideByID)
-		ridesGroup.GET("/:id/status", middleware.RequireRole("USER"), ridesHandler.GetRideStatus)
-		ridesGroup.POST("/:id/cancel", middleware.RequireRole("USER"), ridesHandler.CancelRide)
+	driversGroup := a.r.Group("/drivers")
+	driversGroup.Use(authenticated, requireMFA, middleware.RequirePermission(rbac.DriverApplicationsSubmit))
+	{
+		driversGroup.POST("/application", driversHandler.Apply)
+		driversGroup.GET("/application", driversHandler.GetMyApplication)
+		driversGroup.PUT("/application/documents/:type", driversHandler.UploadDocument)
+	}
+
+	adminGroup := a.r.Group("/admin")
+	adminGroup.Use(authenticated, requireMFA, middleware.AuditMiddleware(auditService))
+	{
+		adminGroup.GET("/users", middleware.RequirePermission(rbac.UsersRead), authHandler.ListUsers)
+		adminGroup.GET("/users/:id", middleware.RequirePermission(rbac.UsersRead), authHandler.GetUser)
+		adminGroup.POST("/users/:id/suspend", middleware.RequirePermission(rbac.UsersManage), authHandler.SuspendUser)
+		adminGroup.POST("/users/:id/unsuspend", middleware.RequirePermission(rbac.UsersManage), authHandler.UnsuspendUser)
+		adminGroup.GET("/users/:id/sessions", middleware.RequirePermission(rbac.UsersRead), authHandler.ListUserSessions)
+		adminGroup.DELETE("/users/:id/sessions/:sessionID", middleware.RequirePermission(rbac.UsersManage), authHandler.RevokeUserSession)
+		adminGroup.POST("/users/:id/logout-all", middleware.RequirePermission(rbac.UsersManage), authHandler.LogoutUserEverywhere)
+		adminGroup.GET("/mfa-policy", middleware.RequirePermission(rbac.MFAPolicy), authHandler.GetMFAPolicy)
+		adminGroup.PUT("/mfa-policy", middleware.RequirePermission(rbac.MFAPolicy), authHandler.SetMFAPolicy)
+
+		adminGroup.POST("/api-keys", middleware.RequirePermission(rbac.APIKeysManage), apiKeysHandler.CreateAPIKey)
+		adminGroup.GET("/api-keys", middleware.RequirePermission(rbac.APIKeysManage), apiKeysHandler.ListAPIKeys)
+		adminGroup.DELETE("/api-keys/:id", middleware.RequirePermission(rbac.APIKeysManage), apiKeysHandler.RevokeAPIKey)
+
+		adminGroup.GET("/rides", middleware.RequirePermission(rbac.RidesReadAny), ridesHandler.ListRides)
+		adminGroup.POST("/rides/:id/cancel", middleware.RequirePermission(rbac.RidesManage), ridesHandler.ForceCancelRide)
+		adminGroup.POST("/rides/:id/reassign", middleware.RequirePermission(rbac.RidesManage), ridesHandler.ReassignRide)
+
+		adminGroup.POST("/areas", middleware.RequirePermission(rbac.AreasManage), areasHandler.CreateArea)
+		adminGroup.GET("/areas", middleware.RequirePermission(rbac.AreasManage), areasHandler.ListAreas)
+		adminGroup.GET("/areas/:id", middleware.RequirePermission(rbac.AreasManage), areasHandler.GetArea)
+		adminGroup.PUT("/areas/:id", middleware.RequirePermission(rbac.AreasManage), areasHandler.UpdateArea)
+		adminGroup.DELETE("/areas/:id", middleware.RequirePermission(rbac.AreasManage), areasHandler.DeleteArea)
+
+		adminGroup.POST("/tariffs", middleware.RequirePermission(rbac.TariffsManage), tariffsHandler.CreateTariff)
+		adminGroup.GET("/tariffs", middleware.RequirePermission(rbac.TariffsManage), tariffsHandler.ListTariffs)
+		adminGroup.GET("/tariffs/:id", middleware.RequirePermission(rbac.TariffsManage), tariffsHandler.GetTariff)
+		adminGroup.PUT("/tariffs/:id", middleware.RequirePermission(rbac.TariffsManage), tariffsHandler.UpdateTariff)
+		adminGroup.DELETE("/tariffs/:id", middleware.RequirePermission(rbac.TariffsManage), tariffsHandler.DeleteTariff)
+
+		adminGroup.GET("/driver-applications", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.ListApplications)
+		adminGroup.GET("/driver-applications/:id", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.GetApplication)
+		adminGroup.GET("/driver-applications/:id/documents/:type", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.GetDocument)
+		adminGroup.POST("/driver-applications/:id/approve", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.Approve)
+		adminGroup.POST("/driver-applications/:id/reject", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.Reject)
+		adminGroup.POST("/driver-applications/:id/suspend", middleware.RequirePermission(rbac.DriverApplicationsReview), driversHandler.Suspend)
+
+		adminGroup.GET("/audit-logs", middleware.RequirePermission(rbac.AuditRead), auditHandler.ListLogs)
 	}
 
 	a.r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	a.logger.Info("All routes created")
 }
+
+func (a *App) newMailer() (mailer.Mailer, error) {
+	switch a.cfg.Mail.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(a.cfg.Mail.SMTPHost, a.cfg.Mail.SMTPPort, a.cfg.Mail.SMTPUsername, a.cfg.Mail.SMTPPassword, a.cfg.Mail.From), nil
+	case "file":
+		return mailer.NewFileMailer(a.cfg.Mail.FileDir, a.cfg.Mail.From)
+	default:
+		return mailer.NewLogMailer(a.logger), nil
+	}
+}
+
+// newSMSSender only has development senders: production needs an
+// SMSSender for the chosen gateway.
+func (a *App) newSMSSender() (sms.SMSSender, error) {
+	switch a.cfg.SMS.Driver {
+	case "file":
+		return sms.NewFileSender(a.cfg.SMS.FileDir)
+	default:
+		return sms.NewLogSender(a.logger), nil
+	}
+}
@@ -0,0 +1,51 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type fileSender struct {
+	dir string
+}
+
+// NewFileSender writes every message to a .txt file in dir instead of
+// sending it. Meant for development.
+func NewFileSender(dir string) (SMSSender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create sms directory: %w", err)
+	}
+	return &fileSender{dir: dir}, nil
+}
+
+func (fs *fileSender) Send(ctx context.Context, msg *Message) error {
+	name := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), strings.TrimPrefix(filepath.Base(msg.To), "+"))
+	content := fmt.Sprintf("To: %s\n\n%s\n", msg.To, msg.Body)
+	if err := os.WriteFile(filepath.Join(fs.dir, name), []byte(content), 0o640); err != nil {
+		return fmt.Errorf("failed to write sms: %w", err)
+	}
+	return nil
+}
+
+type logSender struct {
+	logger *slog.Logger
+}
+
+// NewLogSender writes every message to the log instead of sending it. Meant
+// for development: message bodies contain one-time login codes.
+func NewLogSender(logger *slog.Logger) SMSSender {
+	return &logSender{logger: logger}
+}
+
+func (ls *logSender) Send(ctx context.Context, msg *Message) error {
+	ls.logger.Info("sms",
+		slog.String("to", msg.To),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
@@ -0,0 +1,14 @@
+package sms
+
+import "context"
+
+type Message struct {
+	// To is an E.164 phone number.
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages through an SMS gateway.
+type SMSSender interface {
+	Send(ctx context.Context, msg *Message) error
+}
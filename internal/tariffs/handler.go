@@ -0,0 +1,177 @@
+package tariffs
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TariffServiceInterface interface {
+	CreateTariff(ctx context.Context, body *TariffRequest) (*Tariff, *ErrorResponse)
+	GetTariff(ctx context.Context, tariffID int) (*Tariff, *ErrorResponse)
+	ListTariffs(ctx context.Context, areaID int) (*TariffsResponse, *ErrorResponse)
+	UpdateTariff(ctx context.Context, tariffID int, body *TariffRequest) (*Tariff, *ErrorResponse)
+	DeleteTariff(ctx context.Context, tariffID int) (*StatusResponse, *ErrorResponse)
+}
+
+type TariffHandler struct {
+	service TariffServiceInterface
+}
+
+func NewTariffHandler(service TariffServiceInterface) *TariffHandler {
+	return &TariffHandler{
+		service: service,
+	}
+}
+
+// @Summary      Create tariff
+// @Description  Create a tariff version for a service area and vehicle class. It becomes active at effective_from (now if omitted)
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body  body      TariffRequest  true  "Tariff"
+// @Success      200   {object}  Tariff
+// @Failure      400   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/tariffs [post]
+func (th *TariffHandler) CreateTariff(c *gin.Context) {
+	var body TariffRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	tariff, err := th.service.CreateTariff(c, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, tariff)
+}
+
+// @Summary      List tariffs
+// @Description  Get all tariff versions, optionally for one service area
+// @Tags         admin
+// @Produce      json
+// @Param        area_id  query     int  false  "Area ID"
+// @Success      200      {object}  TariffsResponse
+// @Failure      400      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/tariffs [get]
+func (th *TariffHandler) ListTariffs(c *gin.Context) {
+	var areaID int
+	if value := c.Query("area_id"); value != "" {
+		var convertErr error
+		areaID, convertErr = strconv.Atoi(value)
+		if convertErr != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid area ID")
+			return
+		}
+	}
+
+	tariffs, err := th.service.ListTariffs(c, areaID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, tariffs)
+}
+
+// @Summary      Get tariff
+// @Description  Get tariff version by ID
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Tariff ID"
+// @Success      200  {object}  Tariff
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/tariffs/{id} [get]
+func (th *TariffHandler) GetTariff(c *gin.Context) {
+	tariffID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid tariff ID")
+		return
+	}
+
+	tariff, err := th.service.GetTariff(c, tariffID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, tariff)
+}
+
+// @Summary      Update tariff
+// @Description  Update a tariff version that hasn't been used to price any ride yet
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int            true  "Tariff ID"
+// @Param        body  body      TariffRequest  true  "Tariff"
+// @Success      200   {object}  Tariff
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/tariffs/{id} [put]
+func (th *TariffHandler) UpdateTariff(c *gin.Context) {
+	tariffID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid tariff ID")
+		return
+	}
+
+	var body TariffRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	tariff, err := th.service.UpdateTariff(c, tariffID, &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, tariff)
+}
+
+// @Summary      Delete tariff
+// @Description  Delete a tariff version that hasn't been used to price any ride yet
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      int  true  "Tariff ID"
+// @Success      200  {object}  StatusResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     AdminAuth
+// @Router       /admin/tariffs/{id} [delete]
+func (th *TariffHandler) DeleteTariff(c *gin.Context) {
+	tariffID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid tariff ID")
+		return
+	}
+
+	status, err := th.service.DeleteTariff(c, tariffID)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
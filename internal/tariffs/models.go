@@ -0,0 +1,82 @@
+package tariffs
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	errTariffInUse    = errors.New("tariff has already been used to price rides, create a new version instead")
+	errTariffExists   = errors.New("a tariff for this area and vehicle class already starts at this time")
+	errAreaNotFound   = errors.New("service area not found")
+)
+
+// Rates are the prices a fare is calculated from.
+type Rates struct {
+	BaseFare    float64 `json:"base_fare" db:"base_fare"`
+	PerKm       float64 `json:"per_km" db:"per_km"`
+	PerMinute   float64 `json:"per_minute" db:"per_minute"`
+	MinimumFare float64 `json:"minimum_fare" db:"minimum_fare"`
+}
+
+// Price returns the fare for a route of the given distance in meters and
+// duration in seconds.
+func (r Rates) Price(distance, duration float64) float64 {
+	fare := r.BaseFare + distance/1000*r.PerKm + duration/60*r.PerMinute
+	fare = math.Max(fare, r.MinimumFare)
+	return math.Round(fare*100) / 100
+}
+
+// Tariff is one version of the rates for a service area and vehicle class.
+// The version with the latest effective_from that is not in the future is
+// the active one.
+type Tariff struct {
+	ID            int       `json:"id" db:"id"`
+	AreaID        int       `json:"area_id" db:"area_id"`
+	VehicleClass  string    `json:"vehicle_class" db:"vehicle_class"`
+	EffectiveFrom time.Time `json:"effective_from" db:"effective_from"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	Rates
+}
+
+type TariffRequest struct {
+	AreaID        int        `json:"area_id"`
+	VehicleClass  string     `json:"vehicle_class"`
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+	Rates
+}
+
+type TariffsResponse struct {
+	Tariffs []Tariff `json:"tariffs"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
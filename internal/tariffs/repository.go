@@ -0,0 +1,213 @@
+package tariffs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	tariffColumns           = "id, area_id, vehicle_class, effective_from, base_fare, per_km, per_minute, minimum_fare, created_at"
+	foreignKeyViolationCode = "23503"
+	uniqueViolationCode     = "23505"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+func (pr *postgresRepo) CreateTariff(ctx context.Context, tariff *Tariff) (*Tariff, error) {
+	var created Tariff
+
+	err := pr.db.GetContext(ctx, &created, "INSERT INTO tariffs (area_id, vehicle_class, effective_from, base_fare, per_km, per_minute, minimum_fare) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+tariffColumns,
+		tariff.AreaID, tariff.VehicleClass, tariff.EffectiveFrom, tariff.BaseFare, tariff.PerKm, tariff.PerMinute, tariff.MinimumFare,
+	)
+	if err != nil {
+		if mapped := mapConstraintError(err); mapped != nil {
+			return nil, mapped
+		}
+		pr.logger.Error("failed to create tariff",
+			slog.Int("area_id", tariff.AreaID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create tariff: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (pr *postgresRepo) GetTariffByID(ctx context.Context, tariffID int) (*Tariff, error) {
+	var tariff Tariff
+
+	err := pr.db.GetContext(ctx, &tariff, "SELECT "+tariffColumns+" FROM tariffs WHERE id = $1", tariffID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTariffNotFound
+		}
+		pr.logger.Error("failed to get tariff",
+			slog.Int("tariff_id", tariffID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get tariff: %w", err)
+	}
+
+	return &tariff, nil
+}
+
+func (pr *postgresRepo) ListTariffs(ctx context.Context, areaID int) ([]Tariff, error) {
+	tariffs := []Tariff{}
+
+	query := "SELECT " + tariffColumns + " FROM tariffs"
+	args := []interface{}{}
+	if areaID != 0 {
+		query += " WHERE area_id = $1"
+		args = append(args, areaID)
+	}
+	query += " ORDER BY area_id, vehicle_class, effective_from DESC"
+
+	err := pr.db.SelectContext(ctx, &tariffs, query, args...)
+	if err != nil {
+		pr.logger.Error("failed to list tariffs",
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list tariffs: %w", err)
+	}
+
+	return tariffs, nil
+}
+
+func (pr *postgresRepo) GetActiveTariff(ctx context.Context, areaID int, vehicleClass string, at time.Time) (*Tariff, error) {
+	var tariff Tariff
+
+	err := pr.db.GetContext(ctx, &tariff, "SELECT "+tariffColumns+" FROM tariffs WHERE area_id = $1 AND vehicle_class = $2 AND effective_from <= $3 ORDER BY effective_from DESC LIMIT 1", areaID, vehicleClass, at)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTariffNotFound
+		}
+		pr.logger.Error("failed to get active tariff",
+			slog.Int("area_id", areaID),
+			slog.String("vehicle_class", vehicleClass),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get active tariff: %w", err)
+	}
+
+	return &tariff, nil
+}
+
+// UpdateTariff only changes versions that no ride has been priced with, so
+// the history of existing rides stays intact. The tariff row stays locked
+// from the check to the update, which keeps rides from referencing it in
+// between.
+func (pr *postgresRepo) UpdateTariff(ctx context.Context, tariff *Tariff) (*Tariff, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update tariff",
+			slog.Int("tariff_id", tariff.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update tariff: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var inUse bool
+	err = tx.GetContext(ctx, &inUse, "SELECT EXISTS (SELECT 1 FROM rides WHERE tariff_id = t.id) FROM tariffs t WHERE t.id = $1 FOR UPDATE OF t", tariff.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrTariffNotFound
+			return nil, err
+		}
+		pr.logger.Error("failed to lock tariff",
+			slog.Int("tariff_id", tariff.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update tariff: %w", err)
+	}
+
+	if inUse {
+		err = errTariffInUse
+		return nil, err
+	}
+
+	var updated Tariff
+	err = tx.GetContext(ctx, &updated, "UPDATE tariffs SET area_id = $1, vehicle_class = $2, effective_from = $3, base_fare = $4, per_km = $5, per_minute = $6, minimum_fare = $7 WHERE id = $8 RETURNING "+tariffColumns,
+		tariff.AreaID, tariff.VehicleClass, tariff.EffectiveFrom, tariff.BaseFare, tariff.PerKm, tariff.PerMinute, tariff.MinimumFare, tariff.ID,
+	)
+	if err != nil {
+		if mapped := mapConstraintError(err); mapped != nil {
+			return nil, mapped
+		}
+		pr.logger.Error("failed to update tariff",
+			slog.Int("tariff_id", tariff.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update tariff: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update tariff",
+			slog.Int("tariff_id", tariff.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update tariff: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func (pr *postgresRepo) DeleteTariff(ctx context.Context, tariffID int) error {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM tariffs WHERE id = $1", tariffID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolationCode {
+			return errTariffInUse
+		}
+		pr.logger.Error("failed to delete tariff",
+			slog.Int("tariff_id", tariffID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to delete tariff: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete tariff: %w", err)
+	}
+	if deleted == 0 {
+		return ErrTariffNotFound
+	}
+
+	return nil
+}
+
+func mapConstraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	switch pqErr.Code {
+	case uniqueViolationCode:
+		return errTariffExists
+	case foreignKeyViolationCode:
+		return errAreaNotFound
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package tariffs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/go-ride/internal/vehicles"
+)
+
+type RepositoryInterface interface {
+	CreateTariff(ctx context.Context, tariff *Tariff) (*Tariff, error)
+	GetTariffByID(ctx context.Context, tariffID int) (*Tariff, error)
+	ListTariffs(ctx context.Context, areaID int) ([]Tariff, error)
+	GetActiveTariff(ctx context.Context, areaID int, vehicleClass string, at time.Time) (*Tariff, error)
+	UpdateTariff(ctx context.Context, tariff *Tariff) (*Tariff, error)
+	DeleteTariff(ctx context.Context, tariffID int) error
+}
+
+type TariffService struct {
+	repo   RepositoryInterface
+	logger *slog.Logger
+}
+
+func NewTariffService(repository RepositoryInterface, logger *slog.Logger) *TariffService {
+	return &TariffService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+func (ts *TariffService) CreateTariff(ctx context.Context, body *TariffRequest) (*Tariff, *ErrorResponse) {
+	tariff, errResp := tariffFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	created, err := ts.repo.CreateTariff(ctx, tariff)
+	if err != nil {
+		return nil, tariffErrorResponse(err)
+	}
+
+	ts.logger.Info("tariff created",
+		slog.Int("tariff_id", created.ID),
+		slog.Int("area_id", created.AreaID),
+		slog.String("vehicle_class", created.VehicleClass),
+	)
+
+	return created, nil
+}
+
+func (ts *TariffService) GetTariff(ctx context.Context, tariffID int) (*Tariff, *ErrorResponse) {
+	tariff, err := ts.repo.GetTariffByID(ctx, tariffID)
+	if err != nil {
+		return nil, tariffErrorResponse(err)
+	}
+	return tariff, nil
+}
+
+func (ts *TariffService) ListTariffs(ctx context.Context, areaID int) (*TariffsResponse, *ErrorResponse) {
+	tariffs, err := ts.repo.ListTariffs(ctx, areaID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &TariffsResponse{Tariffs: tariffs}, nil
+}
+
+func (ts *TariffService) UpdateTariff(ctx context.Context, tariffID int, body *TariffRequest) (*Tariff, *ErrorResponse) {
+	tariff, errResp := tariffFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+	tariff.ID = tariffID
+
+	updated, err := ts.repo.UpdateTariff(ctx, tariff)
+	if err != nil {
+		return nil, tariffErrorResponse(err)
+	}
+
+	ts.logger.Info("tariff updated",
+		slog.Int("tariff_id", tariffID),
+	)
+
+	return updated, nil
+}
+
+func (ts *TariffService) DeleteTariff(ctx context.Context, tariffID int) (*StatusResponse, *ErrorResponse) {
+	err := ts.repo.DeleteTariff(ctx, tariffID)
+	if err != nil {
+		return nil, tariffErrorResponse(err)
+	}
+
+	ts.logger.Info("tariff deleted",
+		slog.Int("tariff_id", tariffID),
+	)
+
+	return &StatusResponse{Status: "deleted"}, nil
+}
+
+// ActiveTariff returns the tariff version in effect at the given time, or
+// ErrTariffNotFound if the area has none for the vehicle class.
+func (ts *TariffService) ActiveTariff(ctx context.Context, areaID int, vehicleClass string, at time.Time) (*Tariff, error) {
+	return ts.repo.GetActiveTariff(ctx, areaID, vehicleClass, at)
+}
+
+func tariffFromRequest(body *TariffRequest) (*Tariff, *ErrorResponse) {
+	if body.AreaID <= 0 {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("area_id is required"))
+	}
+
+	vehicleClass, ok := vehicles.NormalizeClass(body.VehicleClass)
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("vehicle_class must be one of %s", strings.Join(vehicles.Classes, ", ")))
+	}
+
+	if body.BaseFare < 0 || body.PerKm < 0 || body.PerMinute < 0 || body.MinimumFare < 0 {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("rates can't be negative"))
+	}
+
+	effectiveFrom := time.Now()
+	if body.EffectiveFrom != nil {
+		effectiveFrom = *body.EffectiveFrom
+	}
+
+	return &Tariff{
+		AreaID:        body.AreaID,
+		VehicleClass:  vehicleClass,
+		EffectiveFrom: effectiveFrom,
+		Rates:         body.Rates,
+	}, nil
+}
+
+func tariffErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, ErrTariffNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errAreaNotFound):
+		return NewErrorResponseWithStatus(http.StatusBadRequest, err)
+	case errors.Is(err, errTariffInUse), errors.Is(err, errTariffExists):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
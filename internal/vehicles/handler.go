@@ -0,0 +1,165 @@
+package vehicles
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VehicleServiceInterface interface {
+	CreateVehicle(ctx context.Context, driverID int, body *VehicleRequest) (*Vehicle, *ErrorResponse)
+	GetVehicle(ctx context.Context, vehicleID, driverID int) (*Vehicle, *ErrorResponse)
+	ListVehicles(ctx context.Context, driverID int) (*VehiclesResponse, *ErrorResponse)
+	UpdateVehicle(ctx context.Context, vehicleID, driverID int, body *VehicleRequest) (*Vehicle, *ErrorResponse)
+	DeleteVehicle(ctx context.Context, vehicleID, driverID int) (*StatusResponse, *ErrorResponse)
+}
+
+type VehicleHandler struct {
+	service VehicleServiceInterface
+}
+
+func NewVehicleHandler(service VehicleServiceInterface) *VehicleHandler {
+	return &VehicleHandler{
+		service: service,
+	}
+}
+
+// @Summary      Register vehicle
+// @Description  Register a vehicle for the current driver. An active vehicle replaces the previously active one
+// @Tags         vehicles
+// @Accept       json
+// @Produce      json
+// @Param        body  body      VehicleRequest  true  "Vehicle"
+// @Success      200   {object}  Vehicle
+// @Failure      400   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     DriverAuth
+// @Router       /vehicles [post]
+func (vh *VehicleHandler) CreateVehicle(c *gin.Context) {
+	var body VehicleRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	vehicle, err := vh.service.CreateVehicle(c, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// @Summary      List vehicles
+// @Description  Get all vehicles of the current driver
+// @Tags         vehicles
+// @Produce      json
+// @Success      200  {object}  VehiclesResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     DriverAuth
+// @Router       /vehicles [get]
+func (vh *VehicleHandler) ListVehicles(c *gin.Context) {
+	vehicles, err := vh.service.ListVehicles(c, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, vehicles)
+}
+
+// @Summary      Get vehicle
+// @Description  Get a vehicle of the current driver by ID
+// @Tags         vehicles
+// @Produce      json
+// @Param        id   path      int  true  "Vehicle ID"
+// @Success      200  {object}  Vehicle
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     DriverAuth
+// @Router       /vehicles/{id} [get]
+func (vh *VehicleHandler) GetVehicle(c *gin.Context) {
+	vehicleID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid vehicle ID")
+		return
+	}
+
+	vehicle, err := vh.service.GetVehicle(c, vehicleID, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// @Summary      Update vehicle
+// @Description  Update a vehicle of the current driver
+// @Tags         vehicles
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int             true  "Vehicle ID"
+// @Param        body  body      VehicleRequest  true  "Vehicle"
+// @Success      200   {object}  Vehicle
+// @Failure      400   {object}  ErrorResponse
+// @Failure      404   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Security     DriverAuth
+// @Router       /vehicles/{id} [put]
+func (vh *VehicleHandler) UpdateVehicle(c *gin.Context) {
+	vehicleID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid vehicle ID")
+		return
+	}
+
+	var body VehicleRequest
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid input body")
+		return
+	}
+
+	vehicle, err := vh.service.UpdateVehicle(c, vehicleID, c.GetInt("userID"), &body)
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// @Summary      Delete vehicle
+// @Description  Delete a vehicle of the current driver that hasn't been used for rides
+// @Tags         vehicles
+// @Produce      json
+// @Param        id   path      int  true  "Vehicle ID"
+// @Success      200  {object}  StatusResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Security     DriverAuth
+// @Router       /vehicles/{id} [delete]
+func (vh *VehicleHandler) DeleteVehicle(c *gin.Context) {
+	vehicleID, convertErr := strconv.Atoi(c.Param("id"))
+	if convertErr != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid vehicle ID")
+		return
+	}
+
+	status, err := vh.service.DeleteVehicle(c, vehicleID, c.GetInt("userID"))
+	if err != nil {
+		newErrorResponse(c, err.StatusCode(http.StatusInternalServerError), err.Message)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Message: message})
+}
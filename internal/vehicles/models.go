@@ -0,0 +1,94 @@
+package vehicles
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	EconomyClass = "ECONOMY"
+	ComfortClass = "COMFORT"
+	XLClass      = "XL"
+)
+
+var Classes = []string{EconomyClass, ComfortClass, XLClass}
+
+const (
+	minSeats = 1
+	maxSeats = 8
+)
+
+// Field limits, matching the column sizes of the vehicles table.
+const (
+	maxMakeLength  = 100
+	maxModelLength = 100
+	maxPlateLength = 20
+	maxColorLength = 50
+)
+
+var (
+	ErrNoActiveVehicle = errors.New("you have no active vehicle")
+	errVehicleNotFound = errors.New("vehicle not found")
+	errPlateTaken      = errors.New("a vehicle with this plate is already registered")
+	errVehicleInUse    = errors.New("vehicle has been used for rides and can't be deleted, deactivate it instead")
+	// errActiveVehicleConflict means a concurrent request activated another
+	// vehicle between deactivating the old one and saving this one.
+	errActiveVehicleConflict = errors.New("another vehicle was activated at the same time, try again")
+)
+
+type Vehicle struct {
+	ID        int       `json:"id" db:"id"`
+	DriverID  int       `json:"driver_id" db:"driver_id"`
+	Make      string    `json:"make" db:"make"`
+	Model     string    `json:"model" db:"model"`
+	Plate     string    `json:"plate" db:"plate"`
+	Color     string    `json:"color" db:"color"`
+	Seats     int       `json:"seats" db:"seats"`
+	Class     string    `json:"class" db:"class"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type VehicleRequest struct {
+	Make     string `json:"make"`
+	Model    string `json:"model"`
+	Plate    string `json:"plate"`
+	Color    string `json:"color"`
+	Seats    int    `json:"seats"`
+	Class    string `json:"class"`
+	IsActive *bool  `json:"is_active,omitempty"`
+}
+
+type VehiclesResponse struct {
+	Vehicles []Vehicle `json:"vehicles"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+	status  int
+}
+
+func NewErrorResponse(err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+	}
+}
+
+func NewErrorResponseWithStatus(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Message: err.Error(),
+		status:  status,
+	}
+}
+
+func (e *ErrorResponse) StatusCode(fallback int) int {
+	if e.status == 0 {
+		return fallback
+	}
+	return e.status
+}
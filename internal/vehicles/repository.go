@@ -0,0 +1,236 @@
+package vehicles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	vehicleColumns          = "id, driver_id, make, model, plate, color, seats, class, is_active, created_at, updated_at"
+	foreignKeyViolationCode = "23503"
+	uniqueViolationCode     = "23505"
+	plateConstraint         = "vehicles_plate_key"
+	oneActiveConstraint     = "vehicles_one_active_per_driver_idx"
+)
+
+type postgresRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewRepository(db *sqlx.DB, logger *slog.Logger) RepositoryInterface {
+	return &postgresRepo{db, logger}
+}
+
+// CreateVehicle deactivates the driver's other vehicles when the new one is
+// active, so a driver always has at most one active vehicle.
+func (pr *postgresRepo) CreateVehicle(ctx context.Context, vehicle *Vehicle) (*Vehicle, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to create vehicle",
+			slog.Int("driver_id", vehicle.DriverID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create vehicle: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if vehicle.IsActive {
+		_, err = tx.ExecContext(ctx, "UPDATE vehicles SET is_active = FALSE, updated_at = now() WHERE driver_id = $1 AND is_active", vehicle.DriverID)
+		if err != nil {
+			pr.logger.Error("failed to deactivate vehicles",
+				slog.Int("driver_id", vehicle.DriverID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to create vehicle: %w", err)
+		}
+	}
+
+	var created Vehicle
+	err = tx.GetContext(ctx, &created, "INSERT INTO vehicles (driver_id, make, model, plate, color, seats, class, is_active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+vehicleColumns,
+		vehicle.DriverID, vehicle.Make, vehicle.Model, vehicle.Plate, vehicle.Color, vehicle.Seats, vehicle.Class, vehicle.IsActive,
+	)
+	if err != nil {
+		if mapped := mapUniqueViolation(err); mapped != nil {
+			return nil, mapped
+		}
+		pr.logger.Error("failed to create vehicle",
+			slog.Int("driver_id", vehicle.DriverID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create vehicle: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to create vehicle",
+			slog.Int("driver_id", vehicle.DriverID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to create vehicle: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (pr *postgresRepo) GetVehicle(ctx context.Context, vehicleID, driverID int) (*Vehicle, error) {
+	var vehicle Vehicle
+
+	err := pr.db.GetContext(ctx, &vehicle, "SELECT "+vehicleColumns+" FROM vehicles WHERE id = $1 AND driver_id = $2", vehicleID, driverID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errVehicleNotFound
+		}
+		pr.logger.Error("failed to get vehicle",
+			slog.Int("vehicle_id", vehicleID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get vehicle: %w", err)
+	}
+
+	return &vehicle, nil
+}
+
+func (pr *postgresRepo) GetActiveVehicle(ctx context.Context, driverID int) (*Vehicle, error) {
+	var vehicle Vehicle
+
+	err := pr.db.GetContext(ctx, &vehicle, "SELECT "+vehicleColumns+" FROM vehicles WHERE driver_id = $1 AND is_active", driverID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoActiveVehicle
+		}
+		pr.logger.Error("failed to get active vehicle",
+			slog.Int("driver_id", driverID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to get active vehicle: %w", err)
+	}
+
+	return &vehicle, nil
+}
+
+func (pr *postgresRepo) ListVehicles(ctx context.Context, driverID int) ([]Vehicle, error) {
+	vehicles := []Vehicle{}
+
+	err := pr.db.SelectContext(ctx, &vehicles, "SELECT "+vehicleColumns+" FROM vehicles WHERE driver_id = $1 ORDER BY id", driverID)
+	if err != nil {
+		pr.logger.Error("failed to list vehicles",
+			slog.Int("driver_id", driverID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to list vehicles: %w", err)
+	}
+
+	return vehicles, nil
+}
+
+func (pr *postgresRepo) UpdateVehicle(ctx context.Context, vehicle *Vehicle) (*Vehicle, error) {
+	tx, err := pr.db.BeginTxx(ctx, nil)
+	if err != nil {
+		pr.logger.Error("failed to update vehicle",
+			slog.Int("vehicle_id", vehicle.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if vehicle.IsActive {
+		_, err = tx.ExecContext(ctx, "UPDATE vehicles SET is_active = FALSE, updated_at = now() WHERE driver_id = $1 AND id <> $2 AND is_active", vehicle.DriverID, vehicle.ID)
+		if err != nil {
+			pr.logger.Error("failed to deactivate vehicles",
+				slog.Int("driver_id", vehicle.DriverID),
+				slog.String("error", err.Error()),
+			)
+			return nil, fmt.Errorf("failed to update vehicle: %w", err)
+		}
+	}
+
+	var updated Vehicle
+	err = tx.GetContext(ctx, &updated, "UPDATE vehicles SET make = $1, model = $2, plate = $3, color = $4, seats = $5, class = $6, is_active = $7, updated_at = now() WHERE id = $8 AND driver_id = $9 RETURNING "+vehicleColumns,
+		vehicle.Make, vehicle.Model, vehicle.Plate, vehicle.Color, vehicle.Seats, vehicle.Class, vehicle.IsActive, vehicle.ID, vehicle.DriverID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errVehicleNotFound
+		}
+		if mapped := mapUniqueViolation(err); mapped != nil {
+			return nil, mapped
+		}
+		pr.logger.Error("failed to update vehicle",
+			slog.Int("vehicle_id", vehicle.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pr.logger.Error("failed to update vehicle",
+			slog.Int("vehicle_id", vehicle.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to update vehicle: %w", err)
+	}
+
+	return &updated, nil
+}
+
+func (pr *postgresRepo) DeleteVehicle(ctx context.Context, vehicleID, driverID int) error {
+	res, err := pr.db.ExecContext(ctx, "DELETE FROM vehicles WHERE id = $1 AND driver_id = $2", vehicleID, driverID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolationCode {
+			return errVehicleInUse
+		}
+		pr.logger.Error("failed to delete vehicle",
+			slog.Int("vehicle_id", vehicleID),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed to delete vehicle: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete vehicle: %w", err)
+	}
+	if deleted == 0 {
+		return errVehicleNotFound
+	}
+
+	return nil
+}
+
+// mapUniqueViolation returns the error for a unique constraint the driver can
+// run into, or nil for any other error.
+func mapUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolationCode {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case plateConstraint:
+		return errPlateTaken
+	case oneActiveConstraint:
+		return errActiveVehicleConflict
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+package vehicles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+type RepositoryInterface interface {
+	CreateVehicle(ctx context.Context, vehicle *Vehicle) (*Vehicle, error)
+	GetVehicle(ctx context.Context, vehicleID, driverID int) (*Vehicle, error)
+	GetActiveVehicle(ctx context.Context, driverID int) (*Vehicle, error)
+	ListVehicles(ctx context.Context, driverID int) ([]Vehicle, error)
+	UpdateVehicle(ctx context.Context, vehicle *Vehicle) (*Vehicle, error)
+	DeleteVehicle(ctx context.Context, vehicleID, driverID int) error
+}
+
+type VehicleService struct {
+	repo   RepositoryInterface
+	logger *slog.Logger
+}
+
+func NewVehicleService(repository RepositoryInterface, logger *slog.Logger) *VehicleService {
+	return &VehicleService{
+		repo:   repository,
+		logger: logger,
+	}
+}
+
+func (vs *VehicleService) CreateVehicle(ctx context.Context, driverID int, body *VehicleRequest) (*Vehicle, *ErrorResponse) {
+	vehicle, errResp := vehicleFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+	vehicle.DriverID = driverID
+
+	created, err := vs.repo.CreateVehicle(ctx, vehicle)
+	if err != nil {
+		return nil, vehicleErrorResponse(err)
+	}
+
+	vs.logger.Info("vehicle created",
+		slog.Int("vehicle_id", created.ID),
+		slog.Int("driver_id", driverID),
+	)
+
+	return created, nil
+}
+
+func (vs *VehicleService) GetVehicle(ctx context.Context, vehicleID, driverID int) (*Vehicle, *ErrorResponse) {
+	vehicle, err := vs.repo.GetVehicle(ctx, vehicleID, driverID)
+	if err != nil {
+		return nil, vehicleErrorResponse(err)
+	}
+	return vehicle, nil
+}
+
+func (vs *VehicleService) ListVehicles(ctx context.Context, driverID int) (*VehiclesResponse, *ErrorResponse) {
+	vehicles, err := vs.repo.ListVehicles(ctx, driverID)
+	if err != nil {
+		return nil, NewErrorResponse(err)
+	}
+	return &VehiclesResponse{Vehicles: vehicles}, nil
+}
+
+func (vs *VehicleService) UpdateVehicle(ctx context.Context, vehicleID, driverID int, body *VehicleRequest) (*Vehicle, *ErrorResponse) {
+	vehicle, errResp := vehicleFromRequest(body)
+	if errResp != nil {
+		return nil, errResp
+	}
+	vehicle.ID = vehicleID
+	vehicle.DriverID = driverID
+
+	updated, err := vs.repo.UpdateVehicle(ctx, vehicle)
+	if err != nil {
+		return nil, vehicleErrorResponse(err)
+	}
+
+	vs.logger.Info("vehicle updated",
+		slog.Int("vehicle_id", vehicleID),
+		slog.Int("driver_id", driverID),
+	)
+
+	return updated, nil
+}
+
+func (vs *VehicleService) DeleteVehicle(ctx context.Context, vehicleID, driverID int) (*StatusResponse, *ErrorResponse) {
+	err := vs.repo.DeleteVehicle(ctx, vehicleID, driverID)
+	if err != nil {
+		return nil, vehicleErrorResponse(err)
+	}
+
+	vs.logger.Info("vehicle deleted",
+		slog.Int("vehicle_id", vehicleID),
+		slog.Int("driver_id", driverID),
+	)
+
+	return &StatusResponse{Status: "deleted"}, nil
+}
+
+// ActiveVehicle returns the vehicle the driver currently works with, or
+// ErrNoActiveVehicle if there is none.
+func (vs *VehicleService) ActiveVehicle(ctx context.Context, driverID int) (*Vehicle, error) {
+	return vs.repo.GetActiveVehicle(ctx, driverID)
+}
+
+// NormalizeClass returns the class in its canonical form and whether it is a
+// known vehicle class.
+func NormalizeClass(class string) (string, bool) {
+	class = strings.ToUpper(strings.TrimSpace(class))
+	return class, slices.Contains(Classes, class)
+}
+
+func vehicleFromRequest(body *VehicleRequest) (*Vehicle, *ErrorResponse) {
+	vehicle := &Vehicle{
+		Make:     strings.TrimSpace(body.Make),
+		Model:    strings.TrimSpace(body.Model),
+		Plate:    strings.ToUpper(strings.Join(strings.Fields(body.Plate), "")),
+		Color:    strings.TrimSpace(body.Color),
+		Seats:    body.Seats,
+		IsActive: true,
+	}
+
+	if vehicle.Make == "" || vehicle.Model == "" || vehicle.Plate == "" || vehicle.Color == "" {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("make, model, plate and color are required"))
+	}
+
+	for _, field := range []struct {
+		name      string
+		value     string
+		maxLength int
+	}{
+		{"make", vehicle.Make, maxMakeLength},
+		{"model", vehicle.Model, maxModelLength},
+		{"plate", vehicle.Plate, maxPlateLength},
+		{"color", vehicle.Color, maxColorLength},
+	} {
+		if utf8.RuneCountInString(field.value) > field.maxLength {
+			return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("%s must not be longer than %d characters", field.name, field.maxLength))
+		}
+	}
+
+	if vehicle.Seats < minSeats || vehicle.Seats > maxSeats {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("seats must be between %d and %d", minSeats, maxSeats))
+	}
+
+	class, ok := NormalizeClass(body.Class)
+	if !ok {
+		return nil, NewErrorResponseWithStatus(http.StatusBadRequest, fmt.Errorf("class must be one of %s", strings.Join(Classes, ", ")))
+	}
+	vehicle.Class = class
+
+	if body.IsActive != nil {
+		vehicle.IsActive = *body.IsActive
+	}
+
+	return vehicle, nil
+}
+
+func vehicleErrorResponse(err error) *ErrorResponse {
+	switch {
+	case errors.Is(err, errVehicleNotFound):
+		return NewErrorResponseWithStatus(http.StatusNotFound, err)
+	case errors.Is(err, errPlateTaken), errors.Is(err, errVehicleInUse), errors.Is(err, errActiveVehicleConflict):
+		return NewErrorResponseWithStatus(http.StatusConflict, err)
+	default:
+		return NewErrorResponse(err)
+	}
+}
@@ -0,0 +1,9 @@
+ALTER TABLE rides DROP COLUMN promo_redemption_id;
+
+ALTER TABLE rides DROP COLUMN discount;
+
+ALTER TABLE rides DROP COLUMN fare;
+
+DROP TABLE promo_redemptions;
+
+DROP TABLE promo_codes;
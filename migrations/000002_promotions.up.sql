@@ -0,0 +1,31 @@
+CREATE TABLE promo_codes (
+    id SERIAL PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL,
+    discount_type TEXT NOT NULL CHECK(discount_type IN ('PERCENTAGE', 'FIXED')),
+    discount_value NUMERIC(10, 2) NOT NULL CHECK(discount_value > 0),
+    max_uses INTEGER CHECK(max_uses > 0),
+    max_uses_per_user INTEGER CHECK(max_uses_per_user > 0),
+    used_count INTEGER NOT NULL DEFAULT 0,
+    min_fare NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    city TEXT,
+    valid_from TIMESTAMP NOT NULL DEFAULT now(),
+    valid_until TIMESTAMP,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK(discount_type <> 'PERCENTAGE' OR discount_value <= 100),
+    CHECK(code = upper(code))
+);
+
+CREATE TABLE promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    discount NUMERIC(10, 2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX promo_redemptions_promo_user_idx ON promo_redemptions (promo_code_id, user_id);
+
+ALTER TABLE rides ADD COLUMN fare NUMERIC(10, 2);
+ALTER TABLE rides ADD COLUMN discount NUMERIC(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE rides ADD COLUMN promo_redemption_id INTEGER REFERENCES promo_redemptions(id);
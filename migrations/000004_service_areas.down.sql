@@ -0,0 +1,3 @@
+ALTER TABLE rides DROP COLUMN area_id;
+
+DROP TABLE service_areas;
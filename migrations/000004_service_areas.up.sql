@@ -0,0 +1,13 @@
+CREATE TABLE service_areas (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    geometry JSONB NOT NULL CHECK(geometry->>'type' IN ('Polygon', 'MultiPolygon')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE rides ADD COLUMN area_id INTEGER REFERENCES service_areas(id);
+
+CREATE INDEX rides_area_id_idx ON rides (area_id);
@@ -0,0 +1,5 @@
+ALTER TABLE rides DROP COLUMN tariff_id;
+
+DROP TABLE tariffs;
+
+DROP TABLE vehicle_classes;
@@ -0,0 +1,20 @@
+CREATE TABLE vehicle_classes (
+    name TEXT PRIMARY KEY
+);
+
+INSERT INTO vehicle_classes (name) VALUES ('ECONOMY'), ('COMFORT'), ('XL');
+
+CREATE TABLE tariffs (
+    id SERIAL PRIMARY KEY,
+    area_id INTEGER NOT NULL REFERENCES service_areas(id) ON DELETE CASCADE,
+    vehicle_class TEXT NOT NULL REFERENCES vehicle_classes(name),
+    effective_from TIMESTAMP NOT NULL DEFAULT now(),
+    base_fare NUMERIC(10, 2) NOT NULL CHECK(base_fare >= 0),
+    per_km NUMERIC(10, 2) NOT NULL CHECK(per_km >= 0),
+    per_minute NUMERIC(10, 2) NOT NULL CHECK(per_minute >= 0),
+    minimum_fare NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK(minimum_fare >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE (area_id, vehicle_class, effective_from)
+);
+
+ALTER TABLE rides ADD COLUMN tariff_id INTEGER REFERENCES tariffs(id);
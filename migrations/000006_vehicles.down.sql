@@ -0,0 +1,5 @@
+ALTER TABLE rides DROP COLUMN vehicle_id;
+
+ALTER TABLE rides DROP COLUMN vehicle_class;
+
+DROP TABLE vehicles;
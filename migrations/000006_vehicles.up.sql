@@ -0,0 +1,18 @@
+CREATE TABLE vehicles (
+    id SERIAL PRIMARY KEY,
+    driver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    make VARCHAR(100) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    plate VARCHAR(20) UNIQUE NOT NULL,
+    color VARCHAR(50) NOT NULL,
+    seats INTEGER NOT NULL CHECK(seats BETWEEN 1 AND 8),
+    class TEXT NOT NULL REFERENCES vehicle_classes(name),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX vehicles_one_active_per_driver_idx ON vehicles (driver_id) WHERE is_active;
+
+ALTER TABLE rides ADD COLUMN vehicle_class TEXT NOT NULL DEFAULT 'ECONOMY' REFERENCES vehicle_classes(name);
+ALTER TABLE rides ADD COLUMN vehicle_id INTEGER REFERENCES vehicles(id);
@@ -0,0 +1,3 @@
+DROP TABLE driver_documents;
+
+DROP TABLE driver_applications;
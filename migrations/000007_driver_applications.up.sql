@@ -0,0 +1,26 @@
+CREATE TABLE driver_applications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'PENDING' CHECK(status IN ('PENDING', 'APPROVED', 'REJECTED', 'SUSPENDED')),
+    review_note TEXT,
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX driver_applications_status_idx ON driver_applications (status);
+
+CREATE TABLE driver_documents (
+    id SERIAL PRIMARY KEY,
+    application_id INTEGER NOT NULL REFERENCES driver_applications(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK(type IN ('LICENSE', 'REGISTRATION', 'INSURANCE')),
+    storage_key TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE(application_id, type)
+);
+
+INSERT INTO driver_applications (user_id, status, reviewed_at)
+SELECT id, 'APPROVED', now() FROM users WHERE role = 'DRIVER';
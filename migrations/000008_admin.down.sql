@@ -0,0 +1,7 @@
+DROP TABLE audit_logs;
+
+ALTER TABLE users DROP COLUMN suspended_at;
+
+UPDATE users SET role = 'USER' WHERE role = 'ADMIN';
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK(role IN ('USER', 'DRIVER'));
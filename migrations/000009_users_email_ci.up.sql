@@ -0,0 +1,16 @@
+-- Emails become unique regardless of case. Accounts whose emails differ
+-- only in case have to be merged or renamed by hand before this migration.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(email, ', ') INTO duplicates
+    FROM (SELECT lower(email) AS email FROM users GROUP BY lower(email) HAVING count(*) > 1) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users with emails that differ only in case must be resolved first: %', duplicates;
+    END IF;
+END
+$$;
+
+CREATE UNIQUE INDEX users_lower_email_idx ON users (lower(email));
@@ -0,0 +1,7 @@
+DELETE FROM refresh_tokens WHERE used_at IS NOT NULL;
+
+DROP INDEX refresh_tokens_expires_at_idx;
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens DROP COLUMN used_at;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
@@ -0,0 +1,5 @@
+-- Plaintext tokens can't be converted without keeping them around, so every
+-- session is revoked and users log in again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
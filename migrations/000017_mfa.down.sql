@@ -0,0 +1,6 @@
+ALTER TABLE sessions DROP COLUMN mfa;
+
+DROP TABLE mfa_required_roles;
+DROP TABLE mfa_challenges;
+DROP TABLE mfa_recovery_codes;
+DROP TABLE user_mfa;
@@ -0,0 +1,5 @@
+DROP TABLE otp_codes;
+
+ALTER TABLE users
+    DROP COLUMN phone_verified_at,
+    DROP COLUMN phone;
@@ -0,0 +1,13 @@
+ALTER TABLE users
+    ADD COLUMN phone TEXT UNIQUE CHECK(phone ~ '^\+[1-9][0-9]{1,14}$'),
+    ADD COLUMN phone_verified_at TIMESTAMP;
+
+CREATE TABLE otp_codes (
+    phone TEXT PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT now(),
+    window_started_at TIMESTAMP NOT NULL DEFAULT now(),
+    sends INTEGER NOT NULL DEFAULT 1
+);
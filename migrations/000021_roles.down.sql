@@ -0,0 +1,5 @@
+UPDATE users SET role = 'USER' WHERE role NOT IN ('USER', 'DRIVER', 'ADMIN');
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK(role IN ('USER', 'DRIVER', 'ADMIN'));
+
+DELETE FROM mfa_required_roles WHERE role NOT IN ('USER', 'DRIVER', 'ADMIN');
+ALTER TABLE mfa_required_roles ADD CONSTRAINT mfa_required_roles_role_check CHECK(role IN ('USER', 'DRIVER', 'ADMIN'));
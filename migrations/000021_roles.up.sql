@@ -0,0 +1,4 @@
+-- Roles are defined by the permission registry in internal/rbac, so the
+-- database no longer pins the list.
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+ALTER TABLE mfa_required_roles DROP CONSTRAINT mfa_required_roles_role_check;
@@ -0,0 +1,5 @@
+DROP TABLE driver_profiles;
+
+ALTER TABLE users
+    DROP COLUMN preferred_language,
+    DROP COLUMN avatar_key;
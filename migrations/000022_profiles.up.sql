@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN preferred_language TEXT,
+    ADD COLUMN avatar_key TEXT;
+
+CREATE TABLE driver_profiles (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    bio TEXT NOT NULL DEFAULT '',
+    languages TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);